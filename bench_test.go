@@ -0,0 +1,162 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type benchAPI struct{}
+
+type benchUser struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+func (b *benchAPI) Sum(_ context.Context, x, y int) int {
+	return x + y
+}
+
+func (b *benchAPI) Save(_ context.Context, user benchUser, notify bool) (*benchUser, error) {
+	return &user, nil
+}
+
+func benchDispatcher() rpc.Option {
+	return rpc.Static(map[string]rpc.Direct[*benchAPI]{
+		"Sum": func(receiver *benchAPI, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 int
+			args.Decode(&arg0)
+			var arg1 int
+			args.Decode(&arg1)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			return receiver.Sum(request.Context(), arg0, arg1), nil
+		},
+		"Save": func(receiver *benchAPI, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 benchUser
+			args.Decode(&arg0)
+			var arg1 bool
+			args.Decode(&arg1)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			return receiver.Save(request.Context(), arg0, arg1)
+		},
+	})
+}
+
+const benchUserPayload = `[{"id": 1, "name": "alice", "email": "alice@example.com", "tags": ["admin", "dev"]}, true]`
+
+// discardWriter is reusable response writer without recording.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardWriter) WriteHeader(status int)      { d.status = status }
+
+func benchHandler(b *testing.B, handler http.Handler, endpoint string, payload string) {
+	b.Helper()
+	request := httptest.NewRequest(http.MethodPost, endpoint, nil)
+	body := bytes.NewReader([]byte(payload))
+	writer := &discardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body.Reset([]byte(payload))
+		request.Body = io.NopCloser(body)
+		for k := range writer.header {
+			delete(writer.header, k)
+		}
+		handler.ServeHTTP(writer, request)
+		if writer.status != http.StatusOK {
+			b.Fatal(writer.status)
+		}
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	index := rpc.Index(&benchAPI{})
+	b.Run("scalars", func(b *testing.B) {
+		benchHandler(b, index["Sum"], "/sum", `[1, 2]`)
+	})
+	b.Run("object", func(b *testing.B) {
+		benchHandler(b, index["Save"], "/save", benchUserPayload)
+	})
+}
+
+func BenchmarkStatic(b *testing.B) {
+	index := rpc.Index(&benchAPI{}, benchDispatcher())
+	b.Run("scalars", func(b *testing.B) {
+		benchHandler(b, index["Sum"], "/sum", `[1, 2]`)
+	})
+	b.Run("object", func(b *testing.B) {
+		benchHandler(b, index["Save"], "/save", benchUserPayload)
+	})
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	handler := rpc.Builder(func(r *http.Request) (*benchAPI, error) {
+		return &benchAPI{}, nil
+	})
+	b.Run("scalars", func(b *testing.B) {
+		benchHandler(b, handler, "/sum", `[1, 2]`)
+	})
+	b.Run("object", func(b *testing.B) {
+		benchHandler(b, handler, "/save", benchUserPayload)
+	})
+}
+
+// BenchmarkDecode compares decoding of arguments before handler: buffered (previous path: whole array into
+// raw messages, then each argument again) and stream (current path: each argument directly from body).
+func BenchmarkDecode(b *testing.B) {
+	argTypes := []reflect.Type{reflect.TypeOf(benchUser{}), reflect.TypeOf(true)}
+	body := bytes.NewReader([]byte(benchUserPayload))
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			body.Reset([]byte(benchUserPayload))
+			var params []json.RawMessage
+			if err := json.NewDecoder(body).Decode(&params); err != nil {
+				b.Fatal(err)
+			}
+			for i, argType := range argTypes {
+				value := reflect.New(argType)
+				if err := json.Unmarshal(params[i], value.Interface()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			body.Reset([]byte(benchUserPayload))
+			decoder := json.NewDecoder(body)
+			if _, err := decoder.Token(); err != nil {
+				b.Fatal(err)
+			}
+			for _, argType := range argTypes {
+				value := reflect.New(argType)
+				if err := decoder.Decode(value.Interface()); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if _, err := decoder.Token(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package rpc
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStore keeps encoded responses. Cache is best-effort, so store should ignore (and log, if needed) errors.
+type CacheStore interface {
+	// Get value by key.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set value for TTL.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	// Delete value by key.
+	Delete(ctx context.Context, key string)
+	// DeletePrefix deletes all values with keys starting with prefix.
+	DeletePrefix(ctx context.Context, prefix string)
+}
+
+// MemoryCacheStore is in-memory LRU cache limited by total size (in bytes) of keys and values.
+func MemoryCacheStore(maxSize int) CacheStore {
+	return &memoryCache{maxSize: maxSize, items: make(map[string]*list.Element), order: list.New()}
+}
+
+type memoryCache struct {
+	maxSize int
+	size    int
+	lock    sync.Mutex
+	items   map[string]*list.Element
+	order   *list.List // front is recently used
+}
+
+type cacheItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (item *cacheItem) size() int {
+	return len(item.key) + len(item.value)
+}
+
+func (mc *memoryCache) Get(_ context.Context, key string) ([]byte, bool) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	el, ok := mc.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if time.Now().After(item.expires) {
+		mc.remove(el)
+		return nil, false
+	}
+	mc.order.MoveToFront(el)
+	return item.value, true
+}
+
+func (mc *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	item := &cacheItem{key: key, value: value, expires: time.Now().Add(ttl)}
+	if item.size() > mc.maxSize {
+		return
+	}
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+	mc.items[key] = mc.order.PushFront(item)
+	mc.size += item.size()
+	for mc.size > mc.maxSize {
+		mc.remove(mc.order.Back())
+	}
+}
+
+func (mc *memoryCache) Delete(_ context.Context, key string) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+}
+
+func (mc *memoryCache) DeletePrefix(_ context.Context, prefix string) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	for key, el := range mc.items {
+		if strings.HasPrefix(key, prefix) {
+			mc.remove(el)
+		}
+	}
+}
+
+func (mc *memoryCache) remove(el *list.Element) {
+	item := mc.order.Remove(el).(*cacheItem)
+	delete(mc.items, item.key)
+	mc.size -= item.size()
+}
+
+// CacheStats are counters of cached method.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Cache keeps responses of cacheable methods (see [Cached]). Key of response is method key in index,
+// session (if set) and canonical JSON of arguments.
+type Cache struct {
+	store    CacheStore
+	session  func(r *http.Request) string
+	lock     sync.Mutex
+	counters map[string]*cacheCounters // by method key
+}
+
+type cacheCounters struct {
+	hits   uint64
+	misses uint64
+}
+
+// NewCache creates cache with store. Nil store means in-memory LRU store limited by 32MB.
+// Session (optional) derives part of key from request (for example, user ID), so sessions don't share responses.
+func NewCache(store CacheStore, session func(r *http.Request) string) *Cache {
+	if store == nil {
+		store = MemoryCacheStore(32 << 20)
+	}
+	return &Cache{store: store, session: session, counters: make(map[string]*cacheCounters)}
+}
+
+// Stats of cache hits and misses by method key.
+func (c *Cache) Stats() map[string]CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var stats = make(map[string]CacheStats, len(c.counters))
+	for method, counter := range c.counters {
+		stats[method] = CacheStats{
+			Hits:   atomic.LoadUint64(&counter.hits),
+			Misses: atomic.LoadUint64(&counter.misses),
+		}
+	}
+	return stats
+}
+
+// InvalidateMethod removes all cached responses of method (by key in index) for all sessions.
+func (c *Cache) InvalidateMethod(ctx context.Context, method string) {
+	c.store.DeletePrefix(ctx, method+"\x00")
+}
+
+func (c *Cache) invalidate(ctx context.Context, method string, session string, args []any) {
+	if args == nil {
+		args = []any{}
+	}
+	key, err := cacheKey(method, session, args)
+	if err != nil {
+		return // such arguments can not be cached
+	}
+	c.store.Delete(ctx, key)
+}
+
+func (c *Cache) counter(method string) *cacheCounters {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	counter, ok := c.counters[method]
+	if !ok {
+		counter = &cacheCounters{}
+		c.counters[method] = counter
+	}
+	return counter
+}
+
+func (c *Cache) sessionOf(request *http.Request) string {
+	if c.session == nil {
+		return ""
+	}
+	return c.session(request)
+}
+
+func cacheKey(method string, session string, args []any) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return method + "\x00" + session + "\x00" + string(data), nil
+}
+
+// Cached marks methods (by key in index: Go name or namespace.Name for namespaced methods) as cacheable for TTL.
+// Successful JSON responses are cached by method, session and canonical JSON of arguments (after decoding,
+// so formatting and order of object fields don't matter). Cached responses are served without call,
+// therefore headers and status set by [ResponseFrom] are not cached: methods which set them should not be cached.
+//
+// Methods with files, binary results, streams or asynchronous ([Async]) are never cached. Cache is used only by
+// HTTP handlers. All methods indexed with the option can invalidate cache by [Invalidate] and [InvalidateMethod].
+// Option can be repeated with different caches: each method uses cache of its option.
+//
+// Cache without session is shared between all callers, so indexing panics if such cache is used for method which
+// depends on caller: method with request-scoped parameters (*http.Request, http.Header or registered by [Inject];
+// context.Context is allowed) or any method of [Builder], which creates receiver per request (usually per user).
+// Use session function returning constant to share responses intentionally.
+//
+//	cache := rpc.NewCache(nil, nil)
+//	handler := rpc.New(&server, rpc.Cached(cache, time.Minute, "GetUser"))
+//
+//	func (srv *Server) UpdateUser(ctx context.Context, id int64, user User) error {
+//		// ...
+//		rpc.Invalidate(ctx, "GetUser", id)
+//		return nil
+//	}
+func Cached(cache *Cache, ttl time.Duration, methods ...string) Option {
+	return func(cfg *config) {
+		for _, method := range methods {
+			cfg.cached[method] = cacheRule{cache: cache, ttl: ttl}
+		}
+	}
+}
+
+// cacheRule of cacheable method.
+type cacheRule struct {
+	cache *Cache
+	ttl   time.Duration
+}
+
+// checkSession panics if cacheable method has cache without session, but response depends on caller:
+// receiver is created per request (see [Builder]) or method has request-scoped parameters. Responses of such method
+// would be shared between all callers, which leaks data of one user to another.
+func checkSession(cfg *config, key string, method reflect.Method, inputs []input) {
+	if cfg.perRequest {
+		panic("rpc: cache of " + key + " has no session, cached responses would be shared between sessions of Builder")
+	}
+	for i, in := range inputs {
+		if in.provider != nil && method.Type.In(i) != contextType {
+			panic("rpc: cache of " + key + " has no session, but method depends on request by parameter " +
+				method.Type.In(i).String() + ", cached responses would be shared between callers")
+		}
+	}
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+type cacheContextKey struct{}
+
+// cacheScope of request: caches of methods (by key in index) and request to derive session.
+type cacheScope struct {
+	caches  map[string]*Cache
+	request *http.Request
+}
+
+// Invalidate cached response of method (by key in index) with arguments for session of current request.
+// Arguments must be encoded to the same JSON as arguments of call. It does nothing if method is called
+// not by handler with [Cached] option.
+func Invalidate(ctx context.Context, method string, args ...any) {
+	if scope, ok := ctx.Value(cacheContextKey{}).(*cacheScope); ok {
+		if cache, ok := scope.caches[method]; ok {
+			cache.invalidate(ctx, method, cache.sessionOf(scope.request), args)
+		}
+	}
+}
+
+// InvalidateMethod removes all cached responses of method (by key in index) for all sessions.
+// It does nothing if method is called not by handler with [Cached] option.
+func InvalidateMethod(ctx context.Context, method string) {
+	if scope, ok := ctx.Value(cacheContextKey{}).(*cacheScope); ok {
+		if cache, ok := scope.caches[method]; ok {
+			cache.InvalidateMethod(ctx, method)
+		}
+	}
+}
+
+// withCache adds cache scope to request context, so method can invalidate cache.
+func (em *ExposedMethod) withCache(request *http.Request) *http.Request {
+	scope := &cacheScope{caches: em.caches, request: request}
+	return request.WithContext(context.WithValue(request.Context(), cacheContextKey{}, scope))
+}
+
+// serveCached serves response from cache or calls method and caches successful response.
+func (em *ExposedMethod) serveCached(receiver reflect.Value, writer http.ResponseWriter, request *http.Request, payload io.Reader, meta *Response) {
+	args, status, err := em.prepare(receiver, request, payload, nil)
+	if err != nil {
+		http.Error(writer, err.Error(), status)
+		return
+	}
+	counter := em.cache.counter(em.key)
+	values := make([]any, len(em.plan.payload))
+	for i, pos := range em.plan.payload {
+		values[i] = (*args)[pos].Interface()
+	}
+	key, keyErr := cacheKey(em.key, em.cache.sessionOf(request), values)
+	if keyErr == nil {
+		if data, ok := em.cache.store.Get(request.Context(), key); ok {
+			em.plan.put(args)
+			atomic.AddUint64(&counter.hits, 1)
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write(data)
+			return
+		}
+	}
+	atomic.AddUint64(&counter.misses, 1)
+
+	response, appError := em.call(args)
+	meta.Apply(writer)
+	if appError != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(appError.Error()))
+		return
+	}
+	data, err := encodeJSON(response)
+	if err == nil && keyErr == nil && meta.Status() == 0 {
+		em.cache.store.Set(request.Context(), key, data, em.cacheTTL)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(meta.StatusOr(http.StatusOK))
+	_, _ = writer.Write(data) // empty in case of encoding error, the same as for non-cached methods
+}
@@ -0,0 +1,210 @@
+package rpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddec/rpc"
+)
+
+type User struct {
+	Name string
+	Age  int
+}
+
+type cacheAPI struct {
+	calls int
+	users map[int]User
+}
+
+func (c *cacheAPI) Get(id int) User {
+	c.calls++
+	return c.users[id]
+}
+
+func (c *cacheAPI) Find(filter User) []User {
+	c.calls++
+	return []User{filter}
+}
+
+func (c *cacheAPI) Update(ctx context.Context, id int, user User) {
+	c.users[id] = user
+	rpc.Invalidate(ctx, "Get", id)
+}
+
+func (c *cacheAPI) Reset(ctx context.Context) {
+	rpc.InvalidateMethod(ctx, "Get")
+}
+
+func postAs(handler http.Handler, endpoint, user, payload string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+endpoint, strings.NewReader(payload))
+	req.Header.Set("X-User", user)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestCached(t *testing.T) {
+	api := &cacheAPI{users: map[int]User{1: {Name: "alice"}}}
+	cache := rpc.NewCache(nil, func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})
+	handler := rpc.New(api, rpc.Cached(cache, time.Minute, "Get", "Find"))
+
+	first := postAs(handler, "get", "u1", `[1]`)
+	second := postAs(handler, "get", "u1", ` [ 1 ] `)
+	if api.calls != 1 {
+		t.Fatalf("second call should be cached, got %d calls", api.calls)
+	}
+	if first.Body.String() != second.Body.String() || second.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("cached response differs: %q %q", first.Body.String(), second.Body.String())
+	}
+
+	postAs(handler, "find", "u1", `[{"Name": "bob", "Age": 1}]`)
+	postAs(handler, "find", "u1", `[{"Age": 1, "Name": "bob"}]`)
+	if api.calls != 2 {
+		t.Fatalf("arguments should be canonicalized, got %d calls", api.calls)
+	}
+
+	postAs(handler, "get", "u2", `[1]`)
+	if api.calls != 3 {
+		t.Fatalf("sessions should not share cache, got %d calls", api.calls)
+	}
+
+	postAs(handler, "update", "u1", `[1, {"Name": "carol"}]`)
+	if res := postAs(handler, "get", "u1", `[1]`); !strings.Contains(res.Body.String(), "carol") || api.calls != 4 {
+		t.Fatalf("cache should be invalidated: %s (calls %d)", res.Body.String(), api.calls)
+	}
+	postAs(handler, "get", "u2", `[1]`)
+	if api.calls != 4 {
+		t.Fatalf("cache of another session should be kept, got %d calls", api.calls)
+	}
+
+	postAs(handler, "reset", "u1", `[]`)
+	postAs(handler, "get", "u2", `[1]`)
+	if api.calls != 5 {
+		t.Fatalf("all entries of method should be invalidated, got %d calls", api.calls)
+	}
+
+	if res := postAs(handler, "get", "u1", `["x"]`); res.Code != http.StatusBadRequest {
+		t.Fatalf("invalid payload should be rejected, got %d", res.Code)
+	}
+
+	stats := cache.Stats()
+	if stats["Get"].Hits != 2 || stats["Get"].Misses != 4 || stats["Find"].Hits != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCached_several(t *testing.T) {
+	api := &cacheAPI{users: map[int]User{1: {Name: "alice"}}}
+	users, filters := rpc.NewCache(nil, nil), rpc.NewCache(nil, nil)
+	handler := rpc.New(api, rpc.Cached(users, time.Minute, "Get"), rpc.Cached(filters, time.Minute, "Find"))
+
+	postAs(handler, "get", "", `[1]`)
+	postAs(handler, "get", "", `[1]`)
+	postAs(handler, "find", "", `[{}]`)
+	postAs(handler, "find", "", `[{}]`)
+	if api.calls != 2 {
+		t.Fatalf("both methods should be cached, got %d calls", api.calls)
+	}
+	if len(users.Stats()) != 1 || users.Stats()["Get"].Hits != 1 || filters.Stats()["Find"].Hits != 1 {
+		t.Fatalf("each method should use own cache: %+v %+v", users.Stats(), filters.Stats())
+	}
+
+	postAs(handler, "update", "", `[1, {"Name": "carol"}]`)
+	if res := postAs(handler, "get", "", `[1]`); !strings.Contains(res.Body.String(), "carol") {
+		t.Fatalf("cache of method should be invalidated: %s", res.Body.String())
+	}
+}
+
+func TestCached_builder(t *testing.T) {
+	factory := func(r *http.Request) (*cacheAPI, error) {
+		return &cacheAPI{users: map[int]User{1: {Name: r.Header.Get("X-User")}}}, nil
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("cache without session should not be shared between sessions of builder")
+		}
+	}()
+	rpc.Builder(factory, rpc.Cached(rpc.NewCache(nil, nil), time.Minute, "Get"))
+}
+
+type profileAPI struct {
+	calls int
+}
+
+func (p *profileAPI) Profile(user *principal) string {
+	p.calls++
+	return "secret of " + user.Name
+}
+
+func principalFromHeader(r *http.Request) (*principal, error) {
+	return &principal{Name: r.Header.Get("X-User")}, nil
+}
+
+func TestCached_injected(t *testing.T) {
+	api := &profileAPI{}
+	cache := rpc.NewCache(nil, func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})
+	handler := rpc.New(api, rpc.Inject(principalFromHeader), rpc.Cached(cache, time.Minute, "Profile"))
+
+	postAs(handler, "profile", "alice", `[]`)
+	if res := postAs(handler, "profile", "bob", `[]`); !strings.Contains(res.Body.String(), "secret of bob") {
+		t.Fatalf("principals should get own responses, got %s", res.Body.String())
+	}
+	if res := postAs(handler, "profile", "alice", `[]`); !strings.Contains(res.Body.String(), "secret of alice") || api.calls != 2 {
+		t.Fatalf("response should be cached per principal: %s (calls %d)", res.Body.String(), api.calls)
+	}
+
+	for name, index := range map[string]func(){
+		"New": func() {
+			rpc.New(api, rpc.Inject(principalFromHeader), rpc.Cached(rpc.NewCache(nil, nil), time.Minute, "Profile"))
+		},
+		"Index": func() {
+			rpc.Router(rpc.Index(api, rpc.Cached(rpc.NewCache(nil, nil), time.Minute, "Profile"), rpc.Inject(principalFromHeader)))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("cache without session should not be shared between principals")
+				}
+			}()
+			index()
+		})
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	ctx := context.Background()
+	store := rpc.MemoryCacheStore(8)
+
+	store.Set(ctx, "a", []byte("111"), time.Minute)
+	store.Set(ctx, "b", []byte("222"), time.Minute)
+	if _, ok := store.Get(ctx, "a"); !ok { // a is recently used
+		t.Fatal("a should be cached")
+	}
+	store.Set(ctx, "c", []byte("333"), time.Minute)
+	if _, ok := store.Get(ctx, "b"); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := store.Get(ctx, "a"); !ok {
+		t.Fatal("a should be kept")
+	}
+
+	store.Set(ctx, "d", []byte("4"), -time.Second)
+	if _, ok := store.Get(ctx, "d"); ok {
+		t.Fatal("expired entry should not be returned")
+	}
+
+	store.DeletePrefix(ctx, "")
+	if _, ok := store.Get(ctx, "c"); ok {
+		t.Fatal("all entries should be deleted")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/reddec/rpc/internal/compile"
+	"golang.org/x/tools/go/packages"
+)
+
+//go:embed go.gotemplate
+var templateText string
+
+// request-scoped parameters which can be resolved from request without providers
+var defaultInjected = map[string]string{
+	"context.Context":   "request.Context()",
+	"*net/http.Request": "request",
+	"net/http.Header":   "request.Header",
+}
+
+const (
+	rpcPackage  = "github.com/reddec/rpc"
+	jrpcPackage = "github.com/reddec/rpc/jrpc"
+)
+
+func main() {
+	lineNum, err := strconv.Atoi(os.Getenv("GOLINE"))
+	if err != nil {
+		panic("GOLINE env incorrect")
+	}
+	fileName, err := filepath.Abs(os.Getenv("GOFILE"))
+	if err != nil {
+		panic(err)
+	}
+	packageName := os.Getenv("GOPACKAGE")
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedTypes | packages.NeedImports | packages.NeedName | packages.NeedSyntax,
+	})
+	if err != nil {
+		panic(err)
+	}
+	// find correct package
+	var pkg *packages.Package
+	for _, p := range pkgs {
+		if p.Name == packageName {
+			pkg = p
+			break
+		}
+	}
+	if pkg == nil {
+		panic("unknown package " + packageName)
+	}
+
+	scope := pkg.Types.Scope()
+	var typeName string
+	for _, name := range scope.Names() {
+		tp := scope.Lookup(name)
+		pos := pkg.Fset.Position(tp.Pos())
+		if pos.Filename == fileName && pos.Line == lineNum+1 {
+			typeName = name
+			break
+		}
+	}
+	if typeName == "" {
+		panic("directive should be on top of struct declaration")
+	}
+
+	output := flag.String("out", strings.ToLower(typeName)+"_rpc.go", "Output file")
+	target := flag.String("target", "rpc", "Target package: rpc (positional arguments) or jrpc (single payload)")
+	funcName := flag.String("func", typeName+"Dispatcher", "Name of generated function which returns option")
+	injected := flag.String("inject", "", "Comma-separated list of request-scoped types registered by Inject (ex: *github.com/foo/bar.Principal), methods with them are dispatched by reflection")
+	withDocs := flag.Bool("docs", false, "Generate <type>Docs function with descriptions of methods, types and fields from doc comments (for schema.Docs or jrpc.Docs)")
+	flag.Parse()
+
+	var injectedTypes []string
+	for _, typeName := range strings.Split(*injected, ",") {
+		if typeName = strings.TrimSpace(typeName); typeName != "" {
+			injectedTypes = append(injectedTypes, typeName)
+		}
+	}
+
+	var docs map[string]string
+	if *withDocs {
+		docs = collectDocs(pkg.Syntax, pkg.PkgPath, typeName)
+	}
+
+	code, err := generate(pkg.Types, typeName, *target, *funcName, injectedTypes, docs)
+	if err != nil {
+		panic(err)
+	}
+
+	// save
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(*output, code, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// generate source code of dispatcher for type in package. Non-nil docs are generated as <type>Docs function.
+func generate(pkg *types.Package, typeName string, target string, funcName string, injected []string, docs map[string]string) ([]byte, error) {
+	if target != "rpc" && target != "jrpc" {
+		return nil, fmt.Errorf("unknown target %s", target)
+	}
+	base, ok := pkg.Scope().Lookup(typeName).Type().(*types.Named)
+	if !ok || base.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("%s should be non-generic type", typeName)
+	}
+	if _, isInterface := base.Underlying().(*types.Interface); isInterface {
+		return nil, fmt.Errorf("%s is interface, which is not supported", typeName)
+	}
+
+	var tl = compile.New()
+	for _, typeName := range injected {
+		tl.Inject(typeName)
+	}
+
+	gen := newGenerator(pkg, target)
+	vc := viewContext{
+		Package:  pkg.Name(),
+		Func:     funcName,
+		Type:     typeName,
+		Target:   target,
+		Receiver: "*" + typeName,
+	}
+	for _, call := range tl.ScanCalls(base) {
+		if body, ok := gen.body(call); ok {
+			vc.Methods = append(vc.Methods, method{Name: call.Name, Body: body})
+		}
+	}
+	vc.StdImports, vc.Imports = gen.imports()
+	if docs != nil {
+		vc.HasDocs = true
+		for key, text := range docs {
+			vc.Docs = append(vc.Docs, doc{Key: key, Text: text})
+		}
+		sort.Slice(vc.Docs, func(i, j int) bool {
+			return vc.Docs[i].Key < vc.Docs[j].Key
+		})
+	}
+
+	var buffer bytes.Buffer
+	if err := template.Must(template.New("").Parse(templateText)).Execute(&buffer, &vc); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return code, nil
+}
+
+type viewContext struct {
+	Package    string
+	Func       string
+	Type       string
+	Target     string // rpc or jrpc
+	Receiver   string
+	StdImports []string
+	Imports    []string
+	Methods    []method
+	HasDocs    bool
+	Docs       []doc
+}
+
+type doc struct {
+	Key  string
+	Text string
+}
+
+type method struct {
+	Name string
+	Body string
+}
+
+type generator struct {
+	pkg     *types.Package
+	target  string
+	paths   map[string]string // import path -> name
+	names   map[string]bool   // used import names
+	runtime string            // name of rpc or jrpc package
+}
+
+func newGenerator(pkg *types.Package, target string) *generator {
+	g := &generator{
+		pkg:    pkg,
+		target: target,
+		paths:  map[string]string{},
+		// reserved for signature of direct calls
+		names: map[string]bool{"json": true, "http": true, "receiver": true, "request": true, "args": true, "payload": true},
+	}
+	if target == "jrpc" {
+		g.runtime = g.importName(jrpcPackage, "jrpc")
+	} else {
+		g.runtime = g.importName(rpcPackage, "rpc")
+	}
+	return g
+}
+
+// importName allocates unique name for imported package.
+func (g *generator) importName(path string, name string) string {
+	if alias, ok := g.paths[path]; ok {
+		return alias
+	}
+	alias := name
+	for i := 1; g.names[alias]; i++ {
+		alias = name + strconv.Itoa(i)
+	}
+	g.paths[path] = alias
+	g.names[alias] = true
+	return alias
+}
+
+func (g *generator) qualifier(pkg *types.Package) string {
+	if pkg == g.pkg {
+		return ""
+	}
+	return g.importName(pkg.Path(), pkg.Name())
+}
+
+// imports are import specs (with alias if needed) sorted by path: standard library and others.
+func (g *generator) imports() (std []string, other []string) {
+	for path, name := range g.paths {
+		spec := strconv.Quote(path)
+		if name != filepath.Base(path) {
+			spec = name + " " + spec
+		}
+		if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+			other = append(other, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	return
+}
+
+// body of direct call. Returns false if method should be dispatched by reflection or not exposed.
+func (g *generator) body(call compile.Call) (string, bool) {
+	if call.Source.Type().(*types.Signature).Variadic() {
+		return "", false
+	}
+	var out strings.Builder
+	var args []string
+	var payload int
+	for _, param := range call.Params {
+		typeName := types.TypeString(param.Source.Type(), nil)
+		if expr, ok := defaultInjected[typeName]; ok {
+			args = append(args, expr)
+			continue
+		}
+		if param.Injected || param.File {
+			return "", false
+		}
+		arg := "arg" + strconv.Itoa(payload)
+		fmt.Fprintf(&out, "var %s %s\n", arg, types.TypeString(param.Source.Type(), g.qualifier))
+		if g.target == "jrpc" {
+			fmt.Fprintf(&out, "if err := %s.DecodePayload(payload, &%s); err != nil {\nreturn nil, err\n}\n", g.runtime, arg)
+		} else {
+			fmt.Fprintf(&out, "args.Decode(&%s)\n", arg)
+		}
+		args = append(args, arg)
+		payload++
+	}
+	if g.target == "jrpc" && payload > 1 {
+		return "", false // not exposed by jrpc
+	}
+	if g.target != "jrpc" {
+		out.WriteString("if err := args.Done(); err != nil {\nreturn nil, err\n}\n")
+	}
+	// used by signature of direct call, so imported only if there is at least one
+	if g.target == "jrpc" {
+		g.paths["encoding/json"] = "json"
+	}
+	g.paths["net/http"] = "http"
+
+	invoke := "receiver." + call.Name + "(" + strings.Join(args, ", ") + ")"
+	switch {
+	case call.Result != nil && call.Error:
+		out.WriteString("return " + invoke)
+	case call.Result != nil:
+		out.WriteString("return " + invoke + ", nil")
+	case call.Error:
+		out.WriteString("return nil, " + invoke)
+	default:
+		out.WriteString(invoke + "\nreturn nil, nil")
+	}
+	return out.String(), true
+}
+
+// collectDocs finds doc comments of exported methods of type (by package path, type name and method name,
+// so they are found under any namespace or alias), types declared in package (by package path and name)
+// and their fields (by package path, type name and field name).
+func collectDocs(files []*ast.File, pkgPath string, typeName string) map[string]string {
+	var docs = make(map[string]string)
+	add := func(key string, groups ...*ast.CommentGroup) {
+		for _, group := range groups {
+			if text := strings.TrimSpace(group.Text()); text != "" {
+				docs[key] = text
+				return
+			}
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil && len(decl.Recv.List) == 1 && decl.Name.IsExported() && receiverName(decl.Recv.List[0].Type) == typeName {
+					add(pkgPath+"."+typeName+"."+decl.Name.Name, decl.Doc)
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					key := pkgPath + "." + spec.Name.Name
+					if len(decl.Specs) == 1 {
+						add(key, spec.Doc, decl.Doc)
+					} else {
+						add(key, spec.Doc)
+					}
+					st, ok := spec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						for _, name := range field.Names {
+							add(key+"."+name.Name, field.Doc, field.Comment)
+						}
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"flag"
 	"github.com/reddec/rpc/internal/compile"
+	"github.com/reddec/rpc/naming"
 	"go/token"
 	"go/types"
 	"golang.org/x/tools/go/packages"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
 //go:embed ts.gotemplate
@@ -29,7 +31,7 @@ func main() {
 	packageName := os.Getenv("GOPACKAGE")
 
 	pkgs, err := packages.Load(&packages.Config{
-		Mode: packages.NeedTypes | packages.NeedImports | packages.NeedName | packages.NeedSyntax,
+		Mode: packages.NeedTypes | packages.NeedImports | packages.NeedName | packages.NeedSyntax | packages.NeedTypesInfo,
 	})
 	if err != nil {
 		panic(err)
@@ -62,8 +64,19 @@ func main() {
 
 	output := flag.String("out", strings.ToLower(typeName)+".ts", "Output file")
 	shim := flag.String("shim", "", "Comma-separated list of TS types shim (ex: github.com/jackc/pgtype.JSONB:any")
+	namingName := flag.String("naming", "lower", "Endpoint naming strategy (same as on server): lower, camel, snake, kebab, as-is")
+	nested := flag.Bool("nested", false, "Scan exported fields as nested services (same as rpc.Nested on server)")
+	registry := flag.String("registry", "", "Comma-separated list of services from the package added to client under namespaces (same as rpc.Registry on server, ex: users=UserService,billing=*Billing)")
+	injected := flag.String("inject", "", "Comma-separated list of request-scoped types excluded from arguments (ex: *github.com/foo/bar.Principal)")
+	async := flag.String("async", "", "Comma-separated list of asynchronous methods (same as rpc.Async on server, ex: Export,users.Import)")
+	jobsNamespace := flag.String("jobs", "jobs", "Namespace of jobs service (rpc.Jobs) used by asynchronous methods")
 	flag.Parse()
 
+	strategy, err := naming.Parse(*namingName)
+	if err != nil {
+		panic(err)
+	}
+
 	obj := scope.Lookup(typeName)
 	if obj == nil {
 		panic("typename not found")
@@ -72,6 +85,24 @@ func main() {
 
 	tpl := getTemplate()
 	var tl = compile.New()
+	tl.Naming(strategy)
+	tl.Nested(*nested)
+	for _, typeName := range strings.Split(*injected, ",") {
+		if typeName = strings.TrimSpace(typeName); typeName != "" {
+			tl.Inject(typeName)
+		}
+	}
+
+	for _, method := range strings.Split(*async, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			tl.Async(method)
+		}
+	}
+
+	// polymorphic interfaces are registered by poly.Define in the package itself or in imported packages
+	for _, p := range append(importedPackages(pkg), pkg) {
+		tl.DiscoverUnions(p.TypesInfo, p.Syntax)
+	}
 
 	for _, opt := range strings.Split(*shim, ",") {
 		sourceType, tsType, ok := strings.Cut(opt, ":")
@@ -94,11 +125,18 @@ func main() {
 		return ""
 	})
 	api := tl.ScanAPI(base)
+	for _, service := range strings.Split(*registry, ",") {
+		if service = strings.TrimSpace(service); service != "" {
+			namespace, serviceType := parseService(scope, service)
+			tl.AddService(&api, namespace, serviceType)
+		}
+	}
 
 	vc := viewContext{
 		API:     api,
 		Objects: tl.Objects(),
 		Aliases: tl.Aliases(),
+		Jobs:    newJobEndpoints(*jobsNamespace, strategy),
 	}
 
 	// save
@@ -120,6 +158,44 @@ type viewContext struct {
 	API     compile.API
 	Objects map[string][]compile.Param
 	Aliases map[string]compile.Type
+	Jobs    jobEndpoints
+}
+
+// jobEndpoints of rpc.Jobs service.
+type jobEndpoints struct {
+	Get    string
+	Wait   string
+	Cancel string
+}
+
+func newJobEndpoints(namespace string, strategy naming.Strategy) jobEndpoints {
+	return jobEndpoints{
+		Get:    namespace + "." + strategy("Get"),
+		Wait:   namespace + "." + strategy("Wait"),
+		Cancel: namespace + "." + strategy("Cancel"),
+	}
+}
+
+// serviceScope is used to render nested services with proper indentation.
+type serviceScope struct {
+	Service *compile.API
+	Depth   int
+}
+
+func (sc serviceScope) Indent() string {
+	return strings.Repeat("    ", sc.Depth)
+}
+
+func (sc serviceScope) Pad() int {
+	return 4 * sc.Depth
+}
+
+func (sc serviceScope) Close() string {
+	return strings.Repeat("    ", sc.Depth-1)
+}
+
+func (sc serviceScope) Next(service *compile.API) serviceScope {
+	return serviceScope{Service: service, Depth: sc.Depth + 1}
 }
 
 func getTemplate() *template.Template {
@@ -138,6 +214,61 @@ func getTemplate() *template.Template {
 			}
 			return strings.Join(ans, "\n"+strings.Repeat(" ", ident))
 		},
-		"lower": strings.ToLower,
+		"property": func(name string) string {
+			if isIdentifier(name) {
+				return name
+			}
+			return strconv.Quote(name)
+		},
+		"scope": func(service *compile.API) serviceScope {
+			return serviceScope{Service: service, Depth: 2}
+		},
 	}).Delims("[[", "]]").Parse(templateText))
 }
+
+// parseService parses namespaced service in format namespace=Type (pointer is allowed: namespace=*Type).
+func parseService(scope *types.Scope, def string) (string, *types.Named) {
+	namespace, typeName, ok := strings.Cut(def, "=")
+	if !ok {
+		panic("invalid service definition " + def)
+	}
+	obj := scope.Lookup(strings.TrimPrefix(strings.TrimSpace(typeName), "*"))
+	if obj == nil {
+		panic("service type " + typeName + " not found")
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		panic("service type " + typeName + " is not named type")
+	}
+	return strings.TrimSpace(namespace), named
+}
+
+// importedPackages loads syntax of non-standard packages imported by package.
+func importedPackages(pkg *packages.Package) []*packages.Package {
+	var paths []string
+	for path := range pkg.Imports {
+		if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	imported, err := packages.Load(&packages.Config{
+		Mode: packages.NeedTypes | packages.NeedName | packages.NeedSyntax | packages.NeedTypesInfo,
+	}, paths...)
+	if err != nil {
+		panic(err)
+	}
+	return imported
+}
+
+// isIdentifier checks that name can be used as TS property without quotes.
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,26 @@
+// Code generated by rpc-gen. DO NOT EDIT.
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/reddec/rpc"
+)
+
+// calcDispatcher is statically typed (reflection-free) dispatcher of calc methods for rpc package.
+// Methods which are not listed are dispatched by reflection.
+func calcDispatcher() rpc.Option {
+	return rpc.Static(map[string]rpc.Direct[*calc]{
+		"Sum": func(receiver *calc, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 float64
+			args.Decode(&arg0)
+			var arg1 float64
+			args.Decode(&arg1)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			return receiver.Sum(arg0, arg1), nil
+		},
+	})
+}
@@ -90,6 +90,7 @@ func (c *Calc) Multiple(ctx context.Context, name string, a, b float64, ts time.
 	return false, nil
 }
 
+//go:generate go run github.com/reddec/rpc/cmd/rpc-gen
 type calc struct {
 }
 
@@ -105,7 +106,7 @@ func main() {
 
 	var service calc
 
-	index := rpc.Index(&service)
+	index := rpc.Index(&service, calcDispatcher())
 	openapi := schema.OpenAPI(index,
 		schema.Title("Demo API"),
 		schema.Version("0.0.1"),
@@ -0,0 +1,24 @@
+// Code generated by rpc-gen. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/reddec/rpc/jrpc"
+)
+
+// calcDispatcher is statically typed (reflection-free) dispatcher of calc methods for jrpc package.
+// Methods which are not listed are dispatched by reflection.
+func calcDispatcher() jrpc.Option {
+	return jrpc.Static(map[string]jrpc.Direct[*calc]{
+		"Sum": func(receiver *calc, request *http.Request, payload json.RawMessage) (any, error) {
+			var arg0 Op
+			if err := jrpc.DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Sum(arg0), nil
+		},
+	})
+}
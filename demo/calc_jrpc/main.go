@@ -9,6 +9,7 @@ import (
 	"github.com/reddec/rpc/jrpc"
 )
 
+//go:generate go run github.com/reddec/rpc/cmd/rpc-gen -target jrpc
 type calc struct {
 }
 
@@ -26,7 +27,7 @@ func main() {
 	flag.Parse()
 
 	var service calc
-	http.Handle("/", jrpc.New(&service))
+	http.Handle("/", jrpc.New(&service, calcDispatcher()))
 	fmt.Println("http://" + *bind)
 	_ = http.ListenAndServe(*bind, nil)
 }
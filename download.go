@@ -0,0 +1,13 @@
+package rpc
+
+import (
+	"github.com/reddec/rpc/internal/download"
+)
+
+// Blob is binary content with optional name and content type which can be returned by method. Content is streamed
+// as-is and closed after writing if it implements io.Closer. See [Index] for details.
+//
+//	func (srv *Server) Export(ctx context.Context) (*rpc.Blob, error) {
+//		return &rpc.Blob{Reader: csvReader, Name: "export.csv", ContentType: "text/csv"}, nil
+//	}
+type Blob = download.Blob
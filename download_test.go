@@ -0,0 +1,113 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type exporter struct {
+	closed bool
+}
+
+func (e *exporter) Export(format string) (*rpc.Blob, error) {
+	return &rpc.Blob{
+		Reader:      strings.NewReader("id,name\n1,alice\n"),
+		Name:        "users." + format,
+		ContentType: "text/csv",
+	}, nil
+}
+
+func (e *exporter) Stream(ctx context.Context) io.ReadCloser {
+	rpc.ResponseFrom(ctx).SetStatus(http.StatusAccepted)
+	return e
+}
+
+func (e *exporter) Render() (io.WriterTo, error) {
+	return bytes.NewBufferString("rendered"), nil
+}
+
+func (e *exporter) Fail() (io.ReadCloser, error) {
+	return e, errors.New("failed")
+}
+
+func (e *exporter) Read(p []byte) (int, error) {
+	return copy(p, "stream"), io.EOF
+}
+
+func (e *exporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestDownload(t *testing.T) {
+	srv := &exporter{}
+	index := rpc.Index(srv)
+	if !index["Export"].Binary() || index["Read"].Binary() {
+		t.Fatal("only binary results should be marked")
+	}
+	router := rpc.Router(index)
+
+	t.Run("blob", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/export", bytes.NewBufferString(`["csv"]`)))
+		if rec.Code != http.StatusOK || rec.Body.String() != "id,name\n1,alice\n" {
+			t.Fatal(rec.Code, rec.Body.String())
+		}
+		if v := rec.Header().Get("Content-Type"); v != "text/csv" {
+			t.Error(v)
+		}
+		if v := rec.Header().Get("Content-Disposition"); v != `attachment; filename=users.csv` {
+			t.Error(v)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/export", bytes.NewBufferString(`["csv"]`))
+		req.Header.Set("Range", "bytes=0-6")
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPartialContent || rec.Body.String() != "id,name" {
+			t.Fatal(rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stream", bytes.NewBufferString(`[]`)))
+		if rec.Code != http.StatusAccepted || rec.Body.String() != "stream" {
+			t.Fatal(rec.Code, rec.Body.String())
+		}
+		if v := rec.Header().Get("Content-Type"); v != "application/octet-stream" {
+			t.Error(v)
+		}
+		if !srv.closed {
+			t.Error("content should be closed")
+		}
+	})
+
+	t.Run("writer to", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/render", bytes.NewBufferString(`[]`)))
+		if rec.Code != http.StatusOK || rec.Body.String() != "rendered" {
+			t.Fatal(rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		srv.closed = false
+		if code, body := call(t, router, "/fail", `[]`); code != http.StatusInternalServerError || body != "failed" {
+			t.Error(code, body)
+		}
+		if !srv.closed {
+			t.Error("content should be closed on error")
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyHeader is request header with client-generated key, which makes retries of the call safe.
+const IdempotencyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set for responses replayed from [IdempotencyStore].
+const ReplayedHeader = "Idempotent-Replayed"
+
+// StoredResponse is the first response for idempotency key.
+type StoredResponse struct {
+	Fingerprint string // hash of endpoint and payload
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore keeps responses by idempotency keys. Store is responsible for expiration of keys.
+type IdempotencyStore interface {
+	// Acquire key for processing. Returns stored response if key is already completed, or nil if key is acquired.
+	// It blocks while the key is processed by concurrent request.
+	Acquire(ctx context.Context, key string) (*StoredResponse, error)
+	// Complete saves response and releases key.
+	Complete(ctx context.Context, key string, response *StoredResponse) error
+	// Release key without response, so the next request with the same key is processed.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore keeps responses in memory for TTL after completion. Zero TTL keeps responses forever.
+func MemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &memoryIdempotency{ttl: ttl, entries: make(map[string]*idempotencyEntry)}
+}
+
+type memoryIdempotency struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]*idempotencyEntry
+	cleanup time.Time // next cleanup
+}
+
+type idempotencyEntry struct {
+	done     chan struct{} // closed once completed or released
+	response *StoredResponse
+	expires  time.Time
+}
+
+func (ms *memoryIdempotency) Acquire(ctx context.Context, key string) (*StoredResponse, error) {
+	for {
+		now := time.Now()
+		ms.lock.Lock()
+		ms.collect(now)
+		entry, ok := ms.entries[key]
+		if !ok || ms.expired(entry, now) {
+			ms.entries[key] = &idempotencyEntry{done: make(chan struct{})}
+			ms.lock.Unlock()
+			return nil, nil
+		}
+		response := entry.response
+		ms.lock.Unlock()
+		if response != nil {
+			return response, nil
+		}
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (ms *memoryIdempotency) Complete(_ context.Context, key string, response *StoredResponse) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	entry, ok := ms.entries[key]
+	if !ok || entry.response != nil {
+		return nil
+	}
+	entry.response = response
+	entry.expires = time.Now().Add(ms.ttl)
+	close(entry.done)
+	return nil
+}
+
+func (ms *memoryIdempotency) Release(_ context.Context, key string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	entry, ok := ms.entries[key]
+	if !ok || entry.response != nil {
+		return nil
+	}
+	delete(ms.entries, key)
+	close(entry.done)
+	return nil
+}
+
+func (ms *memoryIdempotency) expired(entry *idempotencyEntry, now time.Time) bool {
+	return ms.ttl > 0 && entry.response != nil && now.After(entry.expires)
+}
+
+// collect expired responses, not more often than half of TTL.
+func (ms *memoryIdempotency) collect(now time.Time) {
+	if ms.ttl <= 0 || now.Before(ms.cleanup) {
+		return
+	}
+	ms.cleanup = now.Add(ms.ttl / 2)
+	for key, entry := range ms.entries {
+		if ms.expired(entry, now) {
+			delete(ms.entries, key)
+		}
+	}
+}
+
+// Idempotent wraps handler ([Router], [Builder], jrpc handler, ...) to support [IdempotencyHeader]. The first response
+// (status, headers and body) for the key is stored and replayed for retries with the same key, endpoint and payload.
+// Concurrent duplicates wait for the first request. Reuse of the key with different endpoint or payload causes
+// 422 Unprocessable Entity. Requests without header are passed as-is.
+//
+// Keys are scoped by session (for example, user ID from authorization header), unless session is nil.
+// Nil store means in-memory store with 24-hour TTL. Payload and response are buffered in memory.
+//
+//	handler := rpc.Idempotent(rpc.New(&server), nil, func(r *http.Request) string {
+//		return r.Header.Get("Authorization")
+//	})
+func Idempotent(handler http.Handler, store IdempotencyStore, session func(r *http.Request) string) http.Handler {
+	if store == nil {
+		store = MemoryIdempotencyStore(24 * time.Hour)
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get(IdempotencyHeader)
+		if key == "" {
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		if session != nil {
+			key = session(request) + "\x00" + key
+		}
+
+		payload, err := io.ReadAll(request.Body)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(payload))
+		fingerprint := requestFingerprint(request, payload)
+
+		stored, err := store.Acquire(request.Context(), key)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if stored != nil {
+			if stored.Fingerprint != fingerprint {
+				http.Error(writer, "idempotency key is already used for another request", http.StatusUnprocessableEntity)
+				return
+			}
+			replay(writer, stored)
+			return
+		}
+
+		var completed bool
+		defer func() {
+			if !completed { // panic
+				_ = store.Release(context.Background(), key)
+			}
+		}()
+		recorder := &responseRecorder{ResponseWriter: writer}
+		handler.ServeHTTP(recorder, request)
+		completed = true
+		if recorder.status == 0 {
+			recorder.WriteHeader(http.StatusOK)
+		}
+		// response is already sent, nothing can be done on error
+		_ = store.Complete(context.Background(), key, &StoredResponse{
+			Fingerprint: fingerprint,
+			Status:      recorder.status,
+			Header:      recorder.header,
+			Body:        recorder.body.Bytes(),
+		})
+	})
+}
+
+func requestFingerprint(request *http.Request, payload []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, request.URL.Path)
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(writer http.ResponseWriter, response *StoredResponse) {
+	for name, values := range response.Header {
+		writer.Header()[name] = values
+	}
+	writer.Header().Set(ReplayedHeader, "true")
+	writer.WriteHeader(response.Status)
+	_, _ = writer.Write(response.Body)
+}
+
+// responseRecorder writes response through and keeps its copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status != 0 {
+		return
+	}
+	rr.status = status
+	rr.header = rr.ResponseWriter.Header().Clone()
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
+
+func (rr *responseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
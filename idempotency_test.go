@@ -0,0 +1,95 @@
+package rpc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type orderAPI struct {
+	orders  int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (o *orderAPI) Create(item string) int32 {
+	if o.started != nil {
+		o.started <- struct{}{}
+		<-o.release
+	}
+	return atomic.AddInt32(&o.orders, 1)
+}
+
+func postWithKey(handler http.Handler, endpoint, key, user, payload string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+endpoint, strings.NewReader(payload))
+	if key != "" {
+		req.Header.Set(rpc.IdempotencyHeader, key)
+	}
+	req.Header.Set("X-User", user)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestIdempotent(t *testing.T) {
+	var api orderAPI
+	handler := rpc.Idempotent(rpc.New(&api), nil, func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})
+
+	first := postWithKey(handler, "create", "k1", "alice", `["book"]`)
+	replayed := postWithKey(handler, "create", "k1", "alice", `["book"]`)
+	if first.Code != http.StatusOK || replayed.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d %d", first.Code, replayed.Code)
+	}
+	if first.Body.String() != replayed.Body.String() || replayed.Header().Get(rpc.ReplayedHeader) != "true" {
+		t.Fatalf("response should be replayed: %q %q", first.Body.String(), replayed.Body.String())
+	}
+	if replayed.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("headers should be replayed")
+	}
+	if api.orders != 1 {
+		t.Fatalf("expected one call, got %d", api.orders)
+	}
+
+	if res := postWithKey(handler, "create", "k1", "alice", `["pen"]`); res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("different payload should be rejected, got %d", res.Code)
+	}
+	if res := postWithKey(handler, "create", "k1", "bob", `["book"]`); res.Code != http.StatusOK || api.orders != 2 {
+		t.Fatalf("keys should be scoped by session, got %d (orders %d)", res.Code, api.orders)
+	}
+	if res := postWithKey(handler, "create", "", "alice", `["book"]`); res.Code != http.StatusOK || api.orders != 3 {
+		t.Fatalf("requests without key should be passed as-is, got %d (orders %d)", res.Code, api.orders)
+	}
+}
+
+func TestIdempotent_concurrent(t *testing.T) {
+	api := orderAPI{started: make(chan struct{}, 2), release: make(chan struct{})}
+	handler := rpc.Idempotent(rpc.New(&api), rpc.MemoryIdempotencyStore(0), nil)
+
+	var wg sync.WaitGroup
+	var responses [2]*httptest.ResponseRecorder
+	for i := range responses {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = postWithKey(handler, "create", "k1", "", `["book"]`)
+		}()
+	}
+	<-api.started
+	close(api.release)
+	wg.Wait()
+
+	if api.orders != 1 {
+		t.Fatalf("duplicate should wait for the first request, got %d calls", api.orders)
+	}
+	if responses[0].Body.String() != responses[1].Body.String() {
+		t.Fatalf("responses should be the same: %q %q", responses[0].Body.String(), responses[1].Body.String())
+	}
+}
@@ -1,13 +1,20 @@
 package compile
 
 import (
+	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/reddec/rpc/naming"
 )
 
+const polyPackage = "github.com/reddec/rpc/poly"
+
 type TSVar struct {
 	Type     string
 	Nillable bool
@@ -36,34 +43,99 @@ type Param struct {
 	Name     string
 	Source   *types.Var
 	Optional bool
+	File     bool // uploaded file (multipart part)
 	TS       TSVar
 }
 
 type Type struct {
 	Source types.Type
+	Binary bool // raw content (io.Reader, io.WriterTo or Blob)
 	TS     TSVar
 }
 
 type Method struct {
 	Name        string
+	Endpoint    string
 	Description string
 	Source      *types.Func
 	Args        []Param
 	Result      *Type // may be nil
+	Async       bool  // called in background, returns job
 }
 
+// ArgNames are names of arguments sent as JSON (files excluded).
 func (m *Method) ArgNames() []string {
 	var ans = make([]string, 0, len(m.Args))
 	for _, a := range m.Args {
+		if a.File {
+			continue
+		}
 		ans = append(ans, a.Name)
 	}
 	return ans
 }
 
+// FileNames are names of file arguments sent as multipart parts.
+func (m *Method) FileNames() []string {
+	var ans []string
+	for _, a := range m.Args {
+		if a.File {
+			ans = append(ans, a.Name)
+		}
+	}
+	return ans
+}
+
+// Call is exposed method with all parameters (including request-scoped and files) in order of signature.
+type Call struct {
+	Name   string
+	Source *types.Func
+	Params []CallParam
+	Result types.Type // may be nil
+	Error  bool       // method returns error
+}
+
+type CallParam struct {
+	Source   *types.Var
+	Injected bool // request-scoped parameter
+	File     bool // uploaded file (multipart part)
+}
+
 type API struct {
 	Name        string
 	Description string
 	Methods     []*Method
+	Services    []*API // nested services
+}
+
+// HasAsync returns true if any of methods (including nested services) is asynchronous.
+func (api *API) HasAsync() bool {
+	for _, m := range api.Methods {
+		if m.Async {
+			return true
+		}
+	}
+	for _, s := range api.Services {
+		if s.HasAsync() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasUploads returns true if any of methods (including nested services) accepts files.
+func (api *API) HasUploads() bool {
+	for _, m := range api.Methods {
+		if len(m.FileNames()) > 0 {
+			return true
+		}
+	}
+	for _, s := range api.Services {
+		if s.HasUploads() {
+			return true
+		}
+	}
+	return false
 }
 
 func New() *TypeLookup {
@@ -73,6 +145,14 @@ func New() *TypeLookup {
 		typesNames:     map[string]int{},
 		typeAliases:    map[string]Type{},
 		typeObjects:    map[string][]Param{},
+		naming:         naming.Lower,
+		async:          map[string]bool{},
+		unions:         map[string]union{},
+		injected: map[string]bool{
+			"context.Context":   true,
+			"*net/http.Request": true,
+			"net/http.Header":   true,
+		},
 		comments: func(pos token.Pos) string {
 			return ""
 		},
@@ -87,6 +167,22 @@ type TypeLookup struct {
 	typeAliases map[string]Type    // type Foo = Bar
 	typeObjects map[string][]Param // type Foo struct
 	comments    func(pos token.Pos) string
+	naming      naming.Strategy
+	nested      bool
+	injected    map[string]bool  // request-scoped types, excluded from arguments
+	async       map[string]bool  // asynchronous methods by key (Name or namespace.Name)
+	unions      map[string]union // polymorphic interfaces by fqdn
+}
+
+// Variant of polymorphic interface: implementation and value of discriminator.
+type Variant struct {
+	Name string
+	Type types.Type
+}
+
+type union struct {
+	property string
+	variants []Variant
 }
 
 func (tl *TypeLookup) Custom(srcType string, ts TSVar) {
@@ -97,20 +193,209 @@ func (tl *TypeLookup) CommentLookup(handler func(pos token.Pos) string) {
 	tl.comments = handler
 }
 
+// Naming sets strategy to convert method and field names to endpoint names. Default is [naming.Lower].
+func (tl *TypeLookup) Naming(strategy naming.Strategy) {
+	tl.naming = strategy
+}
+
+// Nested enables scanning of exported struct fields (pointers or interfaces) as nested services.
+// Should match rpc.Nested option on server side.
+func (tl *TypeLookup) Nested(enabled bool) {
+	tl.nested = enabled
+}
+
+// Inject marks type (fully qualified, ex: *github.com/foo/bar.Principal) as request-scoped parameter,
+// which is not part of arguments. Should match rpc.Inject option on server side.
+func (tl *TypeLookup) Inject(typeName string) {
+	tl.injected[typeName] = true
+}
+
+// Union declares interface as polymorphic type with implementations distinguished by discriminator property.
+// Should match poly.Define on server side. Only poly.Value of interface is described as discriminated union.
+func (tl *TypeLookup) Union(iface types.Type, property string, variants []Variant) {
+	tl.unions[iface.String()] = union{property: property, variants: variants}
+}
+
+// DiscoverUnions finds poly.Define calls in files and declares unions (see [TypeLookup.Union]). Calls with
+// non-constant property or names of variants, or with variants passed by slice are ignored.
+// Info should contain Types, Uses and Instances.
+func (tl *TypeLookup) DiscoverUnions(info *types.Info, files []*ast.File) {
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || call.Ellipsis.IsValid() || len(call.Args) == 0 {
+				return true
+			}
+			iface, ok := polyInstance(info, call.Fun, "Define")
+			if !ok {
+				return true
+			}
+			property, ok := constString(info, call.Args[0])
+			if !ok {
+				return true
+			}
+			var variants []Variant
+			for _, arg := range call.Args[1:] {
+				variant, ok := arg.(*ast.CallExpr)
+				if !ok || len(variant.Args) != 1 {
+					return true
+				}
+				impl, ok := polyInstance(info, variant.Fun, "Variant")
+				if !ok {
+					return true
+				}
+				name, ok := constString(info, variant.Args[0])
+				if !ok {
+					return true
+				}
+				variants = append(variants, Variant{Name: name, Type: impl})
+			}
+			tl.Union(iface, property, variants)
+			return true
+		})
+	}
+}
+
+// polyInstance returns type argument of generic function from package poly called by expression.
+func polyInstance(info *types.Info, fun ast.Expr, name string) (types.Type, bool) {
+	switch index := fun.(type) {
+	case *ast.IndexExpr:
+		fun = index.X
+	case *ast.IndexListExpr:
+		fun = index.X
+	}
+	var ident *ast.Ident
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	case *ast.Ident:
+		ident = f // dot import
+	default:
+		return nil, false
+	}
+	obj := info.Uses[ident]
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != polyPackage || obj.Name() != name {
+		return nil, false
+	}
+	instance, ok := info.Instances[ident]
+	if !ok || instance.TypeArgs.Len() != 1 {
+		return nil, false
+	}
+	return instance.TypeArgs.At(0), true
+}
+
+func constString(info *types.Info, expr ast.Expr) (string, bool) {
+	value := info.Types[expr].Value
+	if value == nil || value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(value), true
+}
+
+// Async marks method by key (Go name or namespace.Name) as asynchronous. Should match rpc.Async option on server side.
+func (tl *TypeLookup) Async(method string) {
+	tl.async[method] = true
+}
+
 func (tl *TypeLookup) ScanAPI(obj *types.Named) API {
 	var api = API{
 		Name:        tl.allocateTypeName(obj.Obj().Name()),
 		Description: tl.comments(obj.Obj().Pos()),
 	}
-	for i := 0; i < obj.NumMethods(); i++ {
-		m := obj.Method(i)
+	tl.scanService(&api, obj, "", []types.Type{obj})
+	return api
+}
+
+// AddService scans service under namespace (used as-is, same as rpc.Registry.Add on server side) and adds it
+// to API as nested service. Empty namespace adds methods at root level.
+func (tl *TypeLookup) AddService(api *API, namespace string, obj *types.Named) {
+	if namespace == "" {
+		tl.scanService(api, obj, "", []types.Type{obj})
+		return
+	}
+	service := &API{
+		Name:        namespace,
+		Description: tl.comments(obj.Obj().Pos()),
+	}
+	tl.scanService(service, obj, namespace, []types.Type{obj})
+	api.Services = append(api.Services, service)
+}
+
+func (tl *TypeLookup) scanService(api *API, obj *types.Named, namespace string, visited []types.Type) {
+	var methods []*types.Func
+	if iface, ok := obj.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			methods = append(methods, iface.Method(i))
+		}
+	} else {
+		for i := 0; i < obj.NumMethods(); i++ {
+			methods = append(methods, obj.Method(i))
+		}
+	}
+
+	for _, m := range methods {
 		fn, ok := tl.scanMethod(m)
 		if !ok {
 			continue
 		}
+		key := fn.Name
+		fn.Endpoint = tl.naming(fn.Name)
+		if namespace != "" {
+			key = namespace + "." + key
+			fn.Endpoint = namespace + "." + fn.Endpoint
+		}
+		// the same restrictions as on server side: files, binary results and streams are synchronous
+		fn.Async = tl.async[key] && len(fn.FileNames()) == 0 && (fn.Result == nil || !fn.Result.Binary && !isChan(fn.Result.Source))
 		api.Methods = append(api.Methods, fn)
 	}
-	return api
+
+	st, ok := obj.Underlying().(*types.Struct)
+	if !tl.nested || !ok {
+		return
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if !field.Exported() || field.Embedded() {
+			continue
+		}
+		tag := reflect.StructTag(st.Tag(i)).Get("rpc")
+		if tag == "-" {
+			continue
+		}
+		var named *types.Named
+		switch t := field.Type().(type) {
+		case *types.Pointer:
+			named, _ = t.Elem().(*types.Named)
+		case *types.Named:
+			if _, isInterface := t.Underlying().(*types.Interface); isInterface {
+				named = t
+			}
+		}
+		if named == nil || containsType(visited, named) {
+			continue
+		}
+		if tag == "" {
+			tag = tl.naming(field.Name())
+		}
+		if namespace != "" {
+			tag = namespace + "." + tag
+		}
+		service := &API{
+			Name:        field.Name(),
+			Description: tl.comments(field.Pos()),
+		}
+		tl.scanService(service, named, tag, append(visited, named))
+		api.Services = append(api.Services, service)
+	}
+}
+
+func containsType(list []types.Type, t types.Type) bool {
+	for _, v := range list {
+		if types.Identical(v, t) {
+			return true
+		}
+	}
+	return false
 }
 
 func (tl *TypeLookup) Aliases() map[string]Type {
@@ -129,10 +414,97 @@ func (tl *TypeLookup) RegisterType(obj *types.Named) string {
 	typeName := tl.allocateTypeName(obj.Obj().Name())
 	tl.registeredType[obj.String()] = typeName
 
+	if values := enumValues(obj); len(values) > 0 {
+		tl.typeAliases[typeName] = Type{TS: TSVar{Type: strings.Join(values, " | ")}, Source: obj}
+		return typeName
+	}
 	tl.defineTypes(typeName, obj.Obj().Type().Underlying())
 	return typeName
 }
 
+// castPoly describes poly.Value of registered interface as discriminated union. Values of unknown interfaces
+// are described by type argument.
+func (tl *TypeLookup) castPoly(value *types.Named) TSVar {
+	iface, ok := value.TypeArgs().At(0).(*types.Named)
+	if !ok {
+		return tl.CastToTypesScript(value.TypeArgs().At(0))
+	}
+	u, ok := tl.unions[iface.String()]
+	if !ok {
+		return tl.CastToTypesScript(iface)
+	}
+	// keyed by poly.Value, since plain interface is described as any
+	alias, ok := tl.registeredType[value.String()]
+	if ok {
+		return TSVar{Type: alias}
+	}
+	typeName := tl.allocateTypeName(iface.Obj().Name())
+	tl.registeredType[value.String()] = typeName
+	tl.typeAliases[typeName] = Type{TS: TSVar{Type: tl.discriminatedUnion(u)}, Source: iface}
+	return TSVar{Type: typeName}
+}
+
+// discriminatedUnion describes variants as intersections with discriminator property.
+func (tl *TypeLookup) discriminatedUnion(u union) string {
+	var options []string
+	for _, v := range u.variants {
+		t := v.Type
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		options = append(options, "("+tl.CastToTypesScript(t).Render()+" & { "+strconv.Quote(u.property)+": "+strconv.Quote(v.Name)+" })")
+	}
+	return strings.Join(options, " | ")
+}
+
+// enumValues finds exported constants of basic type declared in the same package (in order of declaration)
+// and returns them as TS literals. Duplicated values are skipped. Only types marked by Enum method (the same as
+// used by schema generators) are enums: other constants (defaults, bit flags) are not the only allowed values.
+func enumValues(obj *types.Named) []string {
+	if _, ok := obj.Underlying().(*types.Basic); !ok || obj.Obj().Pkg() == nil || !hasEnum(obj) {
+		return nil
+	}
+	scope := obj.Obj().Pkg().Scope()
+	var consts []*types.Const
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && c.Exported() && types.Identical(c.Type(), obj) {
+			consts = append(consts, c)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+
+	var values []string
+	var seen = make(map[string]bool)
+	for _, c := range consts {
+		var value string
+		switch v := c.Val(); v.Kind() {
+		case constant.String:
+			value = strconv.Quote(constant.StringVal(v))
+		case constant.Int, constant.Bool:
+			value = v.ExactString()
+		case constant.Float:
+			f, _ := constant.Float64Val(v)
+			value = strconv.FormatFloat(f, 'g', -1, 64)
+		default:
+			continue
+		}
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// hasEnum checks that type (or pointer to type) has Enum method.
+func hasEnum(obj *types.Named) bool {
+	method, _, _ := types.LookupFieldOrMethod(obj, true, obj.Obj().Pkg(), "Enum")
+	_, ok := method.(*types.Func)
+	return ok
+}
+
 func (tl *TypeLookup) CastToTypesScript(src types.Type) TSVar {
 	switch t := src.(type) {
 	case *types.Basic:
@@ -182,6 +554,8 @@ func (tl *TypeLookup) CastToTypesScript(src types.Type) TSVar {
 			return TSVar{Type: "string"}
 		case pkg.Path() == "encoding/json" && obj.Name() == "RawMessage":
 			return TSVar{Type: "any"}
+		case pkg.Path() == polyPackage && obj.Name() == "Value" && t.TypeArgs().Len() == 1:
+			return tl.castPoly(t)
 		}
 		custom, ok := tl.customTypes[obj.Type().String()]
 		if ok {
@@ -260,27 +634,26 @@ func (tl *TypeLookup) scanMethod(m *types.Func) (*Method, bool) {
 	}
 	var fn = Method{Source: m, Name: m.Name(), Description: tl.comments(m.Pos())}
 	sig := m.Type().Underlying().(*types.Signature)
-	resN := sig.Results().Len()
-	if resN > 2 {
+	result, _, ok := results(sig)
+	if !ok {
 		return nil, false
 	}
-	// if out=2, then the last always should be an error
-	if resN == 2 {
-		if !isError(sig.Results().At(1).Type()) {
-			return nil, false
-		}
-		t := sig.Results().At(0).Type()
-		fn.Result = &Type{Source: t, TS: tl.CastToTypesScript(t)}
-	}
-	// if out=1, then the output should be only if it's not an error
-	if resN == 1 && !isError(sig.Results().At(0).Type()) {
-		t := sig.Results().At(0).Type()
-		fn.Result = &Type{Source: t, TS: tl.CastToTypesScript(t)}
+	if result != nil {
+		fn.Result = tl.result(result)
 	}
 
 	for i := 0; i < sig.Params().Len(); i++ {
 		arg := sig.Params().At(i)
-		if isContext(arg.Type()) {
+		if tl.injected[types.TypeString(arg.Type(), nil)] {
+			continue
+		}
+		if isFile(arg.Type()) {
+			fn.Args = append(fn.Args, Param{
+				Name:   arg.Name(),
+				Source: arg,
+				File:   true,
+				TS:     TSVar{Type: "Blob"},
+			})
 			continue
 		}
 		fn.Args = append(fn.Args, Param{
@@ -293,18 +666,97 @@ func (tl *TypeLookup) scanMethod(m *types.Func) (*Method, bool) {
 	return &fn, true
 }
 
-func isError(tp types.Type) bool {
-	nm, ok := tp.(*types.Named)
+// results of exposed method: optional result and optional error. Returns false if method can not be exposed.
+func results(sig *types.Signature) (result types.Type, hasError bool, ok bool) {
+	switch res := sig.Results(); res.Len() {
+	case 0:
+		return nil, false, true
+	case 1:
+		if isError(res.At(0).Type()) {
+			return nil, true, true
+		}
+		return res.At(0).Type(), false, true
+	case 2:
+		// if out=2, then the last always should be an error
+		return res.At(0).Type(), true, isError(res.At(1).Type())
+	default:
+		return nil, false, false
+	}
+}
+
+// ScanCalls returns exposed methods of type (same criteria as for ScanAPI) with all parameters in order of signature.
+// Types are not mapped to TypeScript. Used to generate statically typed dispatchers.
+func (tl *TypeLookup) ScanCalls(obj *types.Named) []Call {
+	var calls []Call
+	for i := 0; i < obj.NumMethods(); i++ {
+		m := obj.Method(i)
+		if !m.Exported() {
+			continue
+		}
+		sig := m.Type().Underlying().(*types.Signature)
+		result, hasError, ok := results(sig)
+		if !ok {
+			continue
+		}
+		var call = Call{Source: m, Name: m.Name(), Result: result, Error: hasError}
+		for j := 0; j < sig.Params().Len(); j++ {
+			arg := sig.Params().At(j)
+			call.Params = append(call.Params, CallParam{
+				Source:   arg,
+				Injected: tl.injected[types.TypeString(arg.Type(), nil)],
+				File:     isFile(arg.Type()),
+			})
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+func (tl *TypeLookup) result(t types.Type) *Type {
+	if isBinary(t) {
+		return &Type{Source: t, Binary: true, TS: TSVar{Type: "Blob"}}
+	}
+	return &Type{Source: t, TS: tl.CastToTypesScript(t)}
+}
+
+func isChan(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+// isBinary checks types which are streamed as raw content: implementing io.Reader or io.WriterTo.
+func isBinary(tp types.Type) bool {
+	return hasMethod(tp, "Read", "[]byte", "int") || hasMethod(tp, "WriteTo", "io.Writer", "int64")
+}
+
+// hasMethod checks that type has method with single argument and (result, error) output.
+func hasMethod(tp types.Type, name string, arg string, result string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(tp, false, nil, name)
+	fn, ok := obj.(*types.Func)
 	if !ok {
 		return false
 	}
-	return nm.Obj().Pkg() == nil && nm.Obj().Name() == "error"
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 1 && sig.Results().Len() == 2 &&
+		types.TypeString(sig.Params().At(0).Type(), nil) == arg &&
+		types.TypeString(sig.Results().At(0).Type(), nil) == result &&
+		isError(sig.Results().At(1).Type())
 }
 
-func isContext(tp types.Type) bool {
+// isFile checks types which are filled from multipart parts.
+func isFile(tp types.Type) bool {
+	switch types.TypeString(tp, nil) {
+	case "io.Reader", "*mime/multipart.FileHeader", "github.com/reddec/rpc.File", "github.com/reddec/rpc/internal/upload.File":
+		return true
+	default:
+		return false
+	}
+}
+
+func isError(tp types.Type) bool {
 	nm, ok := tp.(*types.Named)
 	if !ok {
 		return false
 	}
-	return nm.Obj().Type().String() == "context.Context"
+	return nm.Obj().Pkg() == nil && nm.Obj().Name() == "error"
 }
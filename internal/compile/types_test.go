@@ -0,0 +1,59 @@
+package compile_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/reddec/rpc/internal/compile"
+)
+
+const enumSource = `package api
+
+type Status string
+
+const (
+	Active  Status = "active"
+	Blocked Status = "blocked"
+)
+
+func (Status) Enum() []any { return []any{Active, Blocked} }
+
+type Priority int
+
+const DefaultPriority Priority = 1
+
+type Flags uint
+
+const (
+	Read Flags = 1 << iota
+	Write
+)
+`
+
+func TestTypeLookup_RegisterType_enum(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "api.go", enumSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/api", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"Status":   `"active" | "blocked"`,
+		"Priority": "number",
+		"Flags":    "number",
+	} {
+		tl := compile.New()
+		alias := tl.RegisterType(pkg.Scope().Lookup(name).Type().(*types.Named))
+		if actual := tl.Aliases()[alias].TS.Type; actual != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package decode helps to decode JSON payload directly from request body without intermediate buffers.
+package decode
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Source remembers error of underlying reader to distinguish it from decoding errors.
+type Source struct {
+	Reader io.Reader
+	err    error
+}
+
+func (s *Source) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// Malformed checks that decoding error is caused by malformed or incomplete JSON or by reader. In such case decoder
+// can not continue. Other errors are caused by value (type mismatch or custom unmarshaller), and the value is consumed.
+func (s *Source) Malformed(err error) bool {
+	var syntaxError *json.SyntaxError
+	return s.err != nil || errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,86 @@
+// Package download writes binary method results (io.Reader, io.WriterTo or Blob) as raw response.
+//
+// Seekable content (io.ReadSeeker) is served by [http.ServeContent], so Range and conditional requests are supported.
+// Other content is streamed as-is.
+package download
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// DefaultContentType used if content type is not set.
+const DefaultContentType = "application/octet-stream"
+
+// Blob is binary content with optional metadata.
+type Blob struct {
+	io.Reader
+	Name        string    // file name, if set response has Content-Disposition: attachment
+	ContentType string    // content type, default is application/octet-stream
+	ModTime     time.Time // optional modification time, used for conditional requests if content is seekable
+}
+
+var (
+	readerType   = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	writerToType = reflect.TypeOf((*io.WriterTo)(nil)).Elem()
+)
+
+// IsBinary returns true if result of type is streamed as raw content: types implementing io.Reader or io.WriterTo
+// (including Blob and *Blob).
+func IsBinary(t reflect.Type) bool {
+	return t.Implements(readerType) || t.Implements(writerToType)
+}
+
+// Write value (with type accepted by IsBinary) to writer. Custom status (non-zero) disables range requests.
+// Content is closed after writing if it implements io.Closer.
+func Write(writer http.ResponseWriter, request *http.Request, value any, status int) {
+	var blob Blob
+	var content any = value
+	switch v := value.(type) {
+	case Blob:
+		blob, content = v, v.Reader
+	case *Blob:
+		if v != nil {
+			blob, content = *v, v.Reader
+		} else {
+			content = nil
+		}
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	setHeaders(writer, blob)
+	if seeker, ok := content.(io.ReadSeeker); ok && status == 0 {
+		http.ServeContent(writer, request, blob.Name, blob.ModTime, seeker)
+		return
+	}
+	writer.WriteHeader(statusOr(status, http.StatusOK))
+	switch v := content.(type) {
+	case io.WriterTo:
+		_, _ = v.WriteTo(writer) // too late to do anything
+	case io.Reader:
+		_, _ = io.Copy(writer, v) // too late to do anything
+	}
+}
+
+func setHeaders(writer http.ResponseWriter, blob Blob) {
+	contentType := blob.ContentType
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+	writer.Header().Set("Content-Type", contentType)
+	if blob.Name != "" {
+		writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": blob.Name}))
+	}
+}
+
+func statusOr(status, defaultStatus int) int {
+	if status != 0 {
+		return status
+	}
+	return defaultStatus
+}
@@ -0,0 +1,112 @@
+// Package framing splits byte streams to messages: newline-delimited or LSP-style (Content-Length header).
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// MaxMessageSize is maximum size of incoming message.
+const MaxMessageSize = 32 << 20
+
+// Reader of messages.
+type Reader struct {
+	reader        *bufio.Reader
+	contentLength bool
+}
+
+// NewReader creates reader of newline-delimited messages or, if contentLength set, messages prefixed by headers
+// with Content-Length and empty line.
+func NewReader(reader io.Reader, contentLength bool) *Reader {
+	return &Reader{reader: bufio.NewReader(reader), contentLength: contentLength}
+}
+
+// Read next message. Empty lines are skipped. Returns io.EOF at the end of input.
+func (r *Reader) Read() ([]byte, error) {
+	if r.contentLength {
+		return r.readContent()
+	}
+	for {
+		line, err := r.readLine()
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.reader.ReadLine()
+		line = append(line, chunk...)
+		if err != nil {
+			return line, err
+		}
+		if len(line) > MaxMessageSize {
+			return nil, errors.New("message too large")
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+func (r *Reader) readContent() ([]byte, error) {
+	header, err := textproto.NewReader(r.reader).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read headers: %w", err)
+	}
+	size, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	if size > MaxMessageSize {
+		return nil, errors.New("message too large")
+	}
+	var message = make([]byte, size)
+	if _, err := io.ReadFull(r.reader, message); err != nil {
+		return nil, fmt.Errorf("read content: %w", err)
+	}
+	return message, nil
+}
+
+// Writer of messages. Safe for concurrent use.
+type Writer struct {
+	lock          sync.Mutex
+	writer        io.Writer
+	contentLength bool
+}
+
+// NewWriter creates writer of newline-delimited messages or, if contentLength set, messages prefixed
+// by Content-Length header. Newline-delimited messages must not contain new lines (compact JSON).
+func NewWriter(writer io.Writer, contentLength bool) *Writer {
+	return &Writer{writer: writer, contentLength: contentLength}
+}
+
+// Write single message.
+func (w *Writer) Write(message []byte) error {
+	var buf bytes.Buffer
+	if w.contentLength {
+		buf.WriteString("Content-Length: " + strconv.Itoa(len(message)) + "\r\n\r\n")
+		buf.Write(message)
+	} else {
+		buf.Write(message)
+		buf.WriteByte('\n')
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := w.writer.Write(buf.Bytes())
+	return err
+}
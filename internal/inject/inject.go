@@ -0,0 +1,40 @@
+// Package inject resolves request-scoped method parameters, which are not part of payload.
+package inject
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// Provider of parameter value for request.
+type Provider func(r *http.Request) (reflect.Value, error)
+
+// Providers maps parameter type to provider.
+type Providers map[reflect.Type]Provider
+
+// New providers with defaults: context.Context (request context), *http.Request and http.Header (request headers).
+func New() Providers {
+	return Providers{
+		reflect.TypeOf((*context.Context)(nil)).Elem(): func(r *http.Request) (reflect.Value, error) {
+			return reflect.ValueOf(r.Context()), nil
+		},
+		reflect.TypeOf((*http.Request)(nil)): func(r *http.Request) (reflect.Value, error) {
+			return reflect.ValueOf(r), nil
+		},
+		reflect.TypeOf(http.Header{}): func(r *http.Request) (reflect.Value, error) {
+			return reflect.ValueOf(r.Header), nil
+		},
+	}
+}
+
+// Typed converts typed provider function to generic provider.
+func Typed[T any](provider func(r *http.Request) (T, error)) (reflect.Type, Provider) {
+	return reflect.TypeOf((*T)(nil)).Elem(), func(r *http.Request) (reflect.Value, error) {
+		v, err := provider(r)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(&v).Elem(), nil
+	}
+}
@@ -0,0 +1,217 @@
+// Package jsonfield describes struct fields the same way as encoding/json does.
+package jsonfield
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tag is parsed `json` tag of struct field.
+type Tag struct {
+	Name      string // name of property, empty if not set in tag
+	Skip      bool   // json:"-"
+	OmitEmpty bool   // property is optional
+	String    bool   // value is encoded as JSON string
+}
+
+// Parse json tag of field. Invalid names are ignored (field name is used), same as by encoding/json.
+// Option "string" is applied only for scalar types.
+func Parse(field reflect.StructField) Tag {
+	value := field.Tag.Get("json")
+	if value == "-" {
+		return Tag{Skip: true}
+	}
+	name, options, _ := strings.Cut(value, ",")
+	var tag Tag
+	if isValidName(name) {
+		tag.Name = name
+	}
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		switch option {
+		case "omitempty":
+			tag.OmitEmpty = true
+		case "string":
+			tag.String = isScalar(field.Type)
+		}
+	}
+	return tag
+}
+
+func isScalar(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+	return false
+}
+
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// backslash and quote chars are reserved, but otherwise any punctuation chars are allowed in a tag name
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// Field is JSON property of struct, possibly promoted from embedded struct.
+type Field struct {
+	Name   string
+	Index  []int        // index sequence for reflect.Value.FieldByIndex
+	Type   reflect.Type // type of struct field as-is
+	Tag    Tag
+	Owner  reflect.Type        // struct which declares field (differs from walked struct for promoted fields)
+	Source reflect.StructField // declaration of field
+	tagged bool                // name from tag
+}
+
+// Fields returns JSON properties of struct in order of declaration. Fields of embedded structs are promoted
+// by encoding/json rules: the shallowest field wins, then field with name from tag; other conflicting fields
+// are ignored.
+func Fields(t reflect.Type) []Field {
+	var current []Field
+	var next = []Field{{Type: t}}
+	var count, nextCount map[reflect.Type]int
+	var visited = map[reflect.Type]bool{}
+	var fields []Field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.Type] {
+				continue
+			}
+			visited[f.Type] = true
+			for i := 0; i < f.Type.NumField(); i++ {
+				sf := f.Type.Field(i)
+				if sf.Anonymous {
+					et := sf.Type
+					if et.Kind() == reflect.Ptr {
+						et = et.Elem()
+					}
+					if !sf.IsExported() && et.Kind() != reflect.Struct {
+						continue // ignore embedded fields of unexported non-struct types
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := Parse(sf)
+				if tag.Skip {
+					continue
+				}
+				index := make([]int, len(f.Index)+1)
+				copy(index, f.Index)
+				index[len(f.Index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if tag.Name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					name := tag.Name
+					if name == "" {
+						name = sf.Name
+					}
+					fields = append(fields, Field{Name: name, Index: index, Type: sf.Type, Tag: tag, Owner: f.Type, Source: sf, tagged: tag.Name != ""})
+					if count[f.Type] > 1 {
+						// the same struct embedded several times at the same level, add duplicate to annihilate field
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+				// embedded struct without name: fields are promoted from next level
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, Field{Name: ft.Name(), Index: index, Type: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].Name != x[j].Name {
+			return x[i].Name < x[j].Name
+		}
+		if len(x[i].Index) != len(x[j].Index) {
+			return len(x[i].Index) < len(x[j].Index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return lessIndex(x[i].Index, x[j].Index)
+	})
+
+	// keep only dominant field for each name
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].Name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].Name != name {
+				break
+			}
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].Index, out[j].Index)
+	})
+	return out
+}
+
+// dominantField of fields with the same name, which are sorted by depth and tag. Returns false if there is
+// conflict: several fields at the same depth with the same tagging.
+func dominantField(fields []Field) (Field, bool) {
+	if len(fields) > 1 && len(fields[0].Index) == len(fields[1].Index) && fields[0].tagged == fields[1].tagged {
+		return Field{}, false
+	}
+	return fields[0], true
+}
+
+func lessIndex(a, b []int) bool {
+	for k, v := range a {
+		if k >= len(b) {
+			return false
+		}
+		if v != b[k] {
+			return v < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// Example of value from `example` tag of field. Tag is parsed as JSON, invalid JSON is used as string.
+func Example(field reflect.StructField) (any, bool) {
+	raw, ok := field.Tag.Lookup("example")
+	if !ok {
+		return nil, false
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw, true
+	}
+	return value, true
+}
@@ -0,0 +1,43 @@
+// Package origin validates Origin header of browser requests to prevent cross-site WebSocket hijacking
+// and DNS rebinding.
+package origin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Allowed checks Origin header of request. Requests without Origin (non-browser clients) are allowed.
+// Origin is allowed if its host (with port) is the same as Host of request, or it matches one of allowed
+// origins: full origin (ex: https://app.example.com), host (ex: app.example.com:8080) or * for any origin.
+// Comparison is case-insensitive.
+func Allowed(request *http.Request, allowed []string) bool {
+	return check(request, allowed, true)
+}
+
+// Listed checks Origin header of request only by allowed origins (see [Allowed]). Host of request is not trusted,
+// since it's controlled by attacker in case of DNS rebinding.
+func Listed(request *http.Request, allowed []string) bool {
+	return check(request, allowed, false)
+}
+
+func check(request *http.Request, allowed []string, sameHost bool) bool {
+	value := request.Header.Get("Origin")
+	if value == "" {
+		return true
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if sameHost && strings.EqualFold(u.Host, request.Host) {
+		return true
+	}
+	for _, item := range allowed {
+		if item == "*" || strings.EqualFold(item, value) || strings.EqualFold(item, u.Host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Package security describes security schemes and tracks security requirements of methods for OpenAPI generators.
+// Schemes are re-exported by packages schema and jrpc.
+package security
+
+import "sort"
+
+// Scheme describes authentication.
+type Scheme struct {
+	Type         string `json:"type" yaml:"type"`                                     // http or apiKey
+	Scheme       string `json:"scheme,omitempty" yaml:"scheme,omitempty"`             // bearer or basic (for http)
+	BearerFormat string `json:"bearerFormat,omitempty" yaml:"bearerFormat,omitempty"` // ex: JWT
+	In           string `json:"in,omitempty" yaml:"in,omitempty"`                     // header, cookie or query (for apiKey)
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`                 // name of header, cookie or query parameter
+	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Bearer is token in Authorization header. Format (optional) is a hint for clients, ex: JWT.
+func Bearer(format string) *Scheme {
+	return &Scheme{Type: "http", Scheme: "bearer", BearerFormat: format}
+}
+
+// Basic is user and password in Authorization header.
+func Basic() *Scheme {
+	return &Scheme{Type: "http", Scheme: "basic"}
+}
+
+// Header is API key in header.
+func Header(header string) *Scheme {
+	return &Scheme{Type: "apiKey", In: "header", Name: header}
+}
+
+// Cookie is API key in cookie.
+func Cookie(cookie string) *Scheme {
+	return &Scheme{Type: "apiKey", In: "cookie", Name: cookie}
+}
+
+// Requirements of methods by names of security schemes.
+type Requirements struct {
+	global  []string
+	methods map[string][]string
+	public  map[string]bool
+}
+
+// Require scheme by methods or by all methods if list is empty.
+func (r *Requirements) Require(scheme string, methods ...string) {
+	if len(methods) == 0 {
+		r.global = append(r.global, scheme)
+		return
+	}
+	if r.methods == nil {
+		r.methods = make(map[string][]string)
+	}
+	for _, method := range methods {
+		r.methods[method] = append(r.methods[method], scheme)
+	}
+}
+
+// Public marks methods as available without authentication.
+func (r *Requirements) Public(methods ...string) {
+	if r.public == nil {
+		r.public = make(map[string]bool)
+	}
+	for _, method := range methods {
+		r.public[method] = true
+	}
+}
+
+// Global requirement: any of schemes.
+func (r *Requirements) Global() []map[string][]string {
+	return alternatives(r.global)
+}
+
+// Method requirement: nil if global requirement is applied, empty requirement for public methods,
+// otherwise any of method schemes.
+func (r *Requirements) Method(method string) []map[string][]string {
+	if r.public[method] {
+		return []map[string][]string{{}}
+	}
+	return alternatives(r.methods[method])
+}
+
+func alternatives(schemes []string) []map[string][]string {
+	if len(schemes) == 0 {
+		return nil
+	}
+	schemes = append([]string(nil), schemes...)
+	sort.Strings(schemes)
+	var ans = make([]map[string][]string, 0, len(schemes))
+	for i, scheme := range schemes {
+		if i == 0 || schemes[i-1] != scheme {
+			ans = append(ans, map[string][]string{scheme: {}})
+		}
+	}
+	return ans
+}
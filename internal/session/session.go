@@ -0,0 +1,143 @@
+// Package session implements message-based call protocol shared by websocket and stream (stdio) transports.
+//
+// Each request is JSON object with id (any JSON value), method and args. Calls are executed concurrently,
+// responses are matched by id. Streams push items before the final response. In-flight call can be canceled.
+//
+//	-> {"id": 1, "method": "sum", "args": [1, 2]}
+//	<- {"id": 1, "result": 3}
+//	<- {"id": 2, "error": "unknown method", "status": 404}
+//	<- {"id": 3, "item": 1}
+//	-> {"id": 3, "cancel": true}
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Request from client.
+type Request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Cancel bool            `json:"cancel,omitempty"`
+}
+
+// Response to client. Message with item is intermediate (stream), other messages complete the call.
+type Response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Item   json.RawMessage `json:"item,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Status int             `json:"status,omitempty"`
+}
+
+// Handler executes call. Context is canceled once call is canceled by client or session is closed.
+type Handler func(ctx context.Context, request Request, call *Call)
+
+// Call sends responses for single request.
+type Call struct {
+	id   json.RawMessage
+	send func(response Response) error
+}
+
+// Result completes call with result (nil for calls without result).
+func (c *Call) Result(result json.RawMessage) error {
+	return c.send(Response{ID: c.id, Result: result})
+}
+
+// Item pushes stream item.
+func (c *Call) Item(item json.RawMessage) error {
+	return c.send(Response{ID: c.id, Item: item})
+}
+
+// Fail completes call with error and status code (same as HTTP).
+func (c *Call) Fail(status int, err error) error {
+	return c.send(Response{ID: c.id, Error: err.Error(), Status: status})
+}
+
+// Serve reads messages until read returns error and waits for in-flight calls. At the end of input (io.EOF)
+// in-flight calls are completed normally, in case of other errors they are canceled.
+// Write is called concurrently and must be safe for it. Limit is maximum number of in-flight calls (zero means
+// unlimited), extra calls fail with status 429 Too Many Requests.
+func Serve(ctx context.Context, read func() ([]byte, error), write func(data []byte) error, handler Handler, limit int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+
+	var lock sync.Mutex
+	var calls = make(map[string]context.CancelFunc) // in-flight calls by id
+	stop := func(id json.RawMessage) {
+		lock.Lock()
+		callCancel, ok := calls[string(id)]
+		delete(calls, string(id))
+		lock.Unlock()
+		if ok {
+			callCancel()
+		}
+	}
+	send := func(response Response) error {
+		if response.ID == nil {
+			response.ID = json.RawMessage("null")
+		}
+		data, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		return write(data)
+	}
+
+	for {
+		data, err := read()
+		if errors.Is(err, io.EOF) {
+			wg.Wait()
+			return nil
+		}
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+		var request Request
+		if err := json.Unmarshal(data, &request); err != nil {
+			_ = send(Response{Error: err.Error(), Status: http.StatusBadRequest})
+			continue
+		}
+		call := &Call{id: request.ID, send: send}
+		if request.Cancel {
+			stop(request.ID)
+			continue
+		}
+
+		callCtx, callCancel := context.WithCancel(ctx)
+		lock.Lock()
+		_, exists := calls[string(request.ID)]
+		exceeded := limit > 0 && len(calls) >= limit
+		if !exists && !exceeded {
+			calls[string(request.ID)] = callCancel
+		}
+		lock.Unlock()
+		if exists {
+			callCancel()
+			_ = call.Fail(http.StatusBadRequest, errors.New("call with the same id is in progress"))
+			continue
+		}
+		if exceeded {
+			callCancel()
+			_ = call.Fail(http.StatusTooManyRequests, errors.New("too many calls in progress"))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer callCancel()
+			defer stop(request.ID)
+			handler(callCtx, request, call)
+		}()
+	}
+}
@@ -0,0 +1,220 @@
+// Package upload reads file arguments from multipart/form-data requests.
+//
+// Request consists of optional part "args" with JSON arguments and file parts "file0", "file1", ... for each
+// file argument in order of method signature.
+//
+// If any of file arguments is *multipart.FileHeader, the whole form is parsed by [http.Request.ParseMultipartForm]
+// (large files are stored in temporary files). Otherwise, parts are streamed: "args" part must be first, and
+// file parts must follow in order. The last file is streamed directly from request body, others are
+// copied to temporary files. Size of args part and of stored files is limited by [Limits].
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+const (
+	// ArgsPart is name of part with JSON arguments.
+	ArgsPart = "args"
+	// MaxMemory used for parsing multipart form (see http.Request.ParseMultipartForm).
+	MaxMemory = 32 << 20
+	// DefaultMaxArgs is default limit of args part.
+	DefaultMaxArgs = 1 << 20
+)
+
+// ErrTooLarge is returned if part exceeds limit.
+var ErrTooLarge = errors.New("part is too large")
+
+// Limits of parts size in bytes. Zero means no limit.
+type Limits struct {
+	Args  int64 // args part, it's kept in memory
+	Spool int64 // each file stored to temporary file: all files except the last one when streaming
+}
+
+// File is uploaded file (multipart part). Content is streamed from request and valid only during call.
+type File struct {
+	io.Reader
+	Name        string // original file name
+	ContentType string // content type of part
+}
+
+var (
+	fileType   = reflect.TypeOf(File{})
+	readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	headerType = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// IsFile returns true if type can be filled from multipart part: io.Reader, *multipart.FileHeader or File.
+func IsFile(t reflect.Type) bool {
+	return t == fileType || t == readerType || t == headerType
+}
+
+// FilePart is name of part for file argument by index (among file arguments).
+func FilePart(index int) string {
+	return "file" + strconv.Itoa(index)
+}
+
+// IsMultipart checks content type of request.
+func IsMultipart(request *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// Read arguments (raw content of args part, nil if not set) and files for each of types from request.
+// Cleanup should be called after method call, even in case of error. Error wraps [ErrTooLarge] if part
+// exceeds limits.
+func Read(request *http.Request, types []reflect.Type, limits Limits) (args json.RawMessage, files []reflect.Value, cleanup func(), err error) {
+	for _, t := range types {
+		if t == headerType {
+			return readForm(request, types, limits)
+		}
+	}
+	return readStream(request, types, limits)
+}
+
+func readForm(request *http.Request, types []reflect.Type, limits Limits) (json.RawMessage, []reflect.Value, func(), error) {
+	var closers []io.Closer
+	cleanup := func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+		if request.MultipartForm != nil {
+			_ = request.MultipartForm.RemoveAll()
+		}
+	}
+	if err := request.ParseMultipartForm(MaxMemory); err != nil {
+		return nil, nil, cleanup, fmt.Errorf("parse multipart form: %w", err)
+	}
+	var args json.RawMessage
+	if v, ok := request.MultipartForm.Value[ArgsPart]; ok && len(v) > 0 {
+		if limits.Args > 0 && int64(len(v[0])) > limits.Args {
+			return nil, nil, cleanup, fmt.Errorf("read args: %w", ErrTooLarge)
+		}
+		args = json.RawMessage(v[0])
+	}
+	var files = make([]reflect.Value, len(types))
+	for i, t := range types {
+		headers := request.MultipartForm.File[FilePart(i)]
+		if len(headers) == 0 {
+			return nil, nil, cleanup, fmt.Errorf("file %s is not set", FilePart(i))
+		}
+		header := headers[0]
+		if t == headerType {
+			files[i] = reflect.ValueOf(header)
+			continue
+		}
+		f, err := header.Open()
+		if err != nil {
+			return nil, nil, cleanup, fmt.Errorf("open file %s: %w", FilePart(i), err)
+		}
+		closers = append(closers, f)
+		files[i] = toValue(t, f, header.Filename, header.Header.Get("Content-Type"))
+	}
+	return args, files, cleanup, nil
+}
+
+func readStream(request *http.Request, types []reflect.Type, limits Limits) (json.RawMessage, []reflect.Value, func(), error) {
+	var temporary []*os.File
+	cleanup := func() {
+		for _, f := range temporary {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}
+	reader, err := request.MultipartReader()
+	if err != nil {
+		return nil, nil, cleanup, fmt.Errorf("read multipart: %w", err)
+	}
+
+	part, err := reader.NextPart()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, cleanup, fmt.Errorf("read part: %w", err)
+	}
+
+	var args json.RawMessage
+	if part != nil && part.FormName() == ArgsPart {
+		args, err = readAll(part, limits.Args)
+		if err != nil {
+			return nil, nil, cleanup, fmt.Errorf("read args: %w", err)
+		}
+		part, err = reader.NextPart()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, cleanup, fmt.Errorf("read part: %w", err)
+		}
+	}
+
+	var files = make([]reflect.Value, len(types))
+	for i, t := range types {
+		if part == nil || part.FormName() != FilePart(i) {
+			return nil, nil, cleanup, fmt.Errorf("expected part %s", FilePart(i))
+		}
+		var content io.Reader = part
+		if i < len(types)-1 {
+			// only the last part can be streamed, previous should be stored
+			tmp, err := spool(part, limits.Spool)
+			if tmp != nil {
+				temporary = append(temporary, tmp)
+			}
+			if err != nil {
+				return nil, nil, cleanup, fmt.Errorf("store part %s: %w", FilePart(i), err)
+			}
+			content = tmp
+		}
+		files[i] = toValue(t, content, part.FileName(), part.Header.Get("Content-Type"))
+		if i < len(types)-1 {
+			part, err = reader.NextPart()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, nil, cleanup, fmt.Errorf("read part: %w", err)
+			}
+		}
+	}
+	return args, files, cleanup, nil
+}
+
+// readAll reads content up to limit (zero means no limit).
+func readAll(part io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(part)
+	}
+	data, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		err = ErrTooLarge
+	}
+	return data, err
+}
+
+// spool content to temporary file up to limit (zero means no limit).
+func spool(part io.Reader, limit int64) (*os.File, error) {
+	f, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		part = io.LimitReader(part, limit+1)
+	}
+	n, err := io.Copy(f, part)
+	if err != nil {
+		return f, err
+	}
+	if limit > 0 && n > limit {
+		return f, ErrTooLarge
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return f, err
+}
+
+func toValue(t reflect.Type, content io.Reader, name, contentType string) reflect.Value {
+	if t == fileType {
+		return reflect.ValueOf(File{Reader: content, Name: name, ContentType: contentType})
+	}
+	return reflect.ValueOf(&content).Elem()
+}
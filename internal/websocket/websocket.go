@@ -0,0 +1,354 @@
+// Package websocket implements minimal RFC 6455 WebSocket protocol: server handshake, client dial (for tests and
+// tools) and message framing. Extensions and sub-protocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Message types (opcodes).
+const (
+	Text   = 1
+	Binary = 2
+
+	continuation = 0
+	closeFrame   = 8
+	pingFrame    = 9
+	pongFrame    = 10
+)
+
+// MaxMessageSize is maximum size of incoming message.
+const MaxMessageSize = 32 << 20
+
+// maxControlSize is maximum payload size of control frame (RFC 6455, section 5.5).
+const maxControlSize = 125
+
+// Close status codes (RFC 6455, section 7.4.1).
+const (
+	closeNormal   = 1000
+	closeProtocol = 1002
+	closeTooLarge = 1009
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrHandshake returned for invalid upgrade request or response.
+	ErrHandshake = errors.New("websocket: bad handshake")
+	// ErrTooLarge returned if incoming message exceeds MaxMessageSize.
+	ErrTooLarge = errors.New("websocket: message too large")
+	// ErrProtocol returned if peer violates protocol.
+	ErrProtocol = errors.New("websocket: protocol error")
+)
+
+// IsUpgrade checks that request asks for websocket upgrade.
+func IsUpgrade(request *http.Request) bool {
+	return request.Method == http.MethodGet &&
+		hasToken(request.Header.Get("Connection"), "upgrade") &&
+		hasToken(request.Header.Get("Upgrade"), "websocket")
+}
+
+// Upgrade validates handshake and hijacks connection. In case of error, response is already written.
+func Upgrade(writer http.ResponseWriter, request *http.Request) (*Conn, error) {
+	key := request.Header.Get("Sec-WebSocket-Key")
+	if !IsUpgrade(request) || key == "" {
+		http.Error(writer, "websocket upgrade expected", http.StatusBadRequest)
+		return nil, ErrHandshake
+	}
+	if request.Header.Get("Sec-WebSocket-Version") != "13" {
+		writer.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(writer, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrHandshake
+	}
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		http.Error(writer, "websocket is not supported by server", http.StatusInternalServerError)
+		return nil, fmt.Errorf("%w: connection can not be hijacked", ErrHandshake)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack: %w", err)
+	}
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+	return newConn(conn, rw.Reader, false), nil
+}
+
+// Dial connects to websocket server (ws:// or http:// URL). Secure connections are not supported.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	u.Scheme = "http"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	if err := request.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("read handshake: %w", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: status %d", ErrHandshake, response.StatusCode)
+	}
+	return newConn(conn, reader, true), nil
+}
+
+// Conn is websocket connection. Reading should be done from single goroutine, writing is safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool // client frames are masked
+
+	writeLock sync.Mutex
+	closed    bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{conn: conn, reader: reader, client: client}
+}
+
+// ReadMessage reads next data message (Text or Binary). Control frames are handled automatically.
+// Returns io.EOF if connection closed by peer. In case of protocol violation ([ErrProtocol]) or too large message
+// ([ErrTooLarge]) connection is closed with corresponding status code.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, message, err := c.readMessage()
+	switch {
+	case errors.Is(err, ErrProtocol):
+		_ = c.writeClose(closeProtocol)
+	case errors.Is(err, ErrTooLarge):
+		_ = c.writeClose(closeTooLarge)
+	}
+	return messageType, message, err
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	var messageType int
+	var message []byte
+	for {
+		final, opcode, payload, err := c.readFrame(MaxMessageSize - len(message))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			_ = c.writeFrame(closeFrame, payload)
+			return 0, nil, io.EOF
+		case continuation:
+			if messageType == 0 {
+				return 0, nil, fmt.Errorf("%w: unexpected continuation frame", ErrProtocol)
+			}
+		case Text, Binary:
+			if messageType != 0 {
+				return 0, nil, fmt.Errorf("%w: unfinished fragmented message", ErrProtocol)
+			}
+			messageType = opcode
+		default:
+			return 0, nil, fmt.Errorf("%w: unknown opcode %d", ErrProtocol, opcode)
+		}
+		message = append(message, payload...)
+		if final {
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage writes single message of type (Text or Binary).
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(messageType, data)
+}
+
+// Close sends close frame (if not sent yet) and closes underlying connection.
+func (c *Conn) Close() error {
+	_ = c.writeClose(closeNormal)
+	return c.conn.Close()
+}
+
+// writeClose sends close frame with status code.
+func (c *Conn) writeClose(code uint16) error {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	return c.writeFrame(closeFrame, payload[:])
+}
+
+// readFrame reads frame with payload up to limit (for data frames). Payload is read as it arrives,
+// so size in header doesn't cause allocation in advance.
+func (c *Conn) readFrame(limit int) (final bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	final = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	if masked == c.client {
+		err = fmt.Errorf("%w: invalid frame masking", ErrProtocol)
+		return
+	}
+	control := opcode >= closeFrame
+	if control && !final {
+		err = fmt.Errorf("%w: fragmented control frame", ErrProtocol)
+		return
+	}
+
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if control && size > maxControlSize {
+		err = fmt.Errorf("%w: control frame payload is %d bytes", ErrProtocol, size)
+		return
+	}
+	if !control && size > uint64(limit) {
+		err = ErrTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload, err = io.ReadAll(io.LimitReader(c.reader, int64(size)))
+	if err != nil {
+		return
+	}
+	if uint64(len(payload)) < size {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	if opcode == closeFrame {
+		c.closed = true
+	}
+
+	var header = make([]byte, 2, 14)
+	header[0] = 0x80 | byte(opcode)
+	switch size := len(payload); {
+	case size < 126:
+		header[1] = byte(size)
+	case size <= 0xffff:
+		header[1] = 126
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(size))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(size))
+	}
+
+	if c.client {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		header[1] |= 0x80
+		header = append(header, mask[:]...)
+		payload = append([]byte(nil), payload...)
+		maskBytes(mask, payload)
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return nil
+}
+
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func hasToken(header, token string) bool {
+	for _, v := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+	return false
+}
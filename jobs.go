@@ -0,0 +1,394 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobStatus is state of asynchronous job.
+type JobStatus string
+
+const (
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// maxWait limits long-polling duration of [Jobs.Wait].
+const maxWait = time.Minute
+
+// ErrJobNotFound is returned for unknown or expired jobs.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is state of asynchronous method call as persisted by store. Result is set for finished (done) jobs
+// of methods with response, Error is set for failed jobs. Owner is session of caller (see [NewJobs]),
+// it must be persisted by store, but it's not exposed to clients (see [JobState]).
+type Job struct {
+	ID      string          `json:"id"`
+	Owner   string          `json:"owner,omitempty"`
+	Method  string          `json:"method"`
+	Status  JobStatus       `json:"status"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Created time.Time       `json:"created"`
+	Updated time.Time       `json:"updated"`
+}
+
+// Finished returns true if job is not running anymore.
+func (job *Job) Finished() bool {
+	return job.Status != JobRunning
+}
+
+// State of job for clients.
+func (job *Job) State() *JobState {
+	return &JobState{
+		ID:      job.ID,
+		Method:  job.Method,
+		Status:  job.Status,
+		Result:  job.Result,
+		Error:   job.Error,
+		Created: job.Created,
+		Updated: job.Updated,
+	}
+}
+
+// JobState is job as returned to clients: without owner.
+type JobState struct {
+	ID      string          `json:"id"`
+	Method  string          `json:"method"`
+	Status  JobStatus       `json:"status"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Created time.Time       `json:"created"`
+	Updated time.Time       `json:"updated"`
+}
+
+// JobStore persists state of jobs. Store should return [ErrJobNotFound] for unknown jobs
+// and is responsible for cleanup of finished jobs.
+type JobStore interface {
+	// Save (create or update) job.
+	Save(ctx context.Context, job *Job) error
+	// Get job by ID.
+	Get(ctx context.Context, id string) (*Job, error)
+	// List all known jobs.
+	List(ctx context.Context) ([]*Job, error)
+}
+
+// MemoryStore keeps jobs in memory. Finished jobs are removed after TTL since last update.
+// Zero TTL keeps jobs forever.
+func MemoryStore(ttl time.Duration) JobStore {
+	return &memoryStore{ttl: ttl, jobs: make(map[string]*Job)}
+}
+
+type memoryStore struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	jobs    map[string]*Job
+	cleanup time.Time // next cleanup
+}
+
+func (ms *memoryStore) Save(_ context.Context, job *Job) error {
+	cp := *job
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.jobs[job.ID] = &cp
+	ms.collect(time.Now())
+	return nil
+}
+
+func (ms *memoryStore) Get(_ context.Context, id string) (*Job, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	job, ok := ms.jobs[id]
+	if !ok || ms.expired(job, time.Now()) {
+		return nil, ErrJobNotFound
+	}
+	cp := *job
+	return &cp, nil
+}
+
+func (ms *memoryStore) List(_ context.Context) ([]*Job, error) {
+	now := time.Now()
+	ms.lock.Lock()
+	ms.collect(now)
+	var list = make([]*Job, 0, len(ms.jobs))
+	for _, job := range ms.jobs {
+		if !ms.expired(job, now) {
+			cp := *job
+			list = append(list, &cp)
+		}
+	}
+	ms.lock.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+func (ms *memoryStore) expired(job *Job, now time.Time) bool {
+	return ms.ttl > 0 && job.Finished() && now.Sub(job.Updated) > ms.ttl
+}
+
+// collect expired jobs, not more often than half of TTL.
+func (ms *memoryStore) collect(now time.Time) {
+	if ms.ttl <= 0 || now.Before(ms.cleanup) {
+		return
+	}
+	ms.cleanup = now.Add(ms.ttl / 2)
+	for id, job := range ms.jobs {
+		if ms.expired(job, now) {
+			delete(ms.jobs, id)
+		}
+	}
+}
+
+// Jobs runs asynchronous methods (see [Async]) and exposes their state. Jobs itself can be exposed as service
+// (for example, by [Registry] under "jobs" namespace) which gives endpoints to get, wait, list and cancel jobs.
+//
+//	jobs := rpc.NewJobs(nil, nil)
+//	index := rpc.NewRegistry().
+//		Add("", &server, rpc.Async(jobs, "Export")). // Export(...) -> POST /export, 202 Accepted with job
+//		Add("jobs", jobs).                           // POST /jobs.get, /jobs.wait, /jobs.list, /jobs.cancel
+//		Index()
+type Jobs struct {
+	store   JobStore
+	session func(r *http.Request) string
+	lock    sync.Mutex
+	running map[string]*runningJob
+}
+
+type runningJob struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed once final state is saved
+	once   sync.Once
+}
+
+// claim right to save final state of job. Returns false if job is already finished or canceled.
+func (rj *runningJob) claim() (first bool) {
+	rj.once.Do(func() {
+		first = true
+	})
+	return
+}
+
+// NewJobs creates jobs runner with store. Nil store means in-memory store with one-hour TTL.
+//
+// Jobs are owned by session of caller (for example, user ID from authorization header): jobs of other sessions
+// are not listed and not found. Nil session means that all jobs are shared, which is fine only if all callers
+// are trusted, otherwise one user can read results of another. Methods of Jobs take request
+// (injected by default) to find session of caller.
+func NewJobs(store JobStore, session func(r *http.Request) string) *Jobs {
+	if store == nil {
+		store = MemoryStore(time.Hour)
+	}
+	return &Jobs{store: store, session: session, running: make(map[string]*runningJob)}
+}
+
+// Get state of job.
+func (j *Jobs) Get(request *http.Request, id string) (*JobState, error) {
+	job, err := j.get(request, id)
+	if err != nil {
+		return nil, err
+	}
+	return job.State(), nil
+}
+
+// Wait up to timeout (in seconds, at most one minute) for job to finish and returns its state.
+// Jobs started by another instance (with shared store) are not waited.
+func (j *Jobs) Wait(request *http.Request, id string, timeout float64) (*JobState, error) {
+	if _, err := j.get(request, id); err != nil {
+		return nil, err
+	}
+	ctx := request.Context()
+	duration := time.Duration(timeout * float64(time.Second))
+	if duration > maxWait {
+		duration = maxWait
+	}
+	j.lock.Lock()
+	rj, ok := j.running[id]
+	j.lock.Unlock()
+	if ok && duration > 0 {
+		timer := time.NewTimer(duration)
+		select {
+		case <-rj.done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}
+	return j.Get(request, id)
+}
+
+// List jobs of caller ordered by creation time.
+func (j *Jobs) List(request *http.Request) ([]*JobState, error) {
+	list, err := j.store.List(request.Context())
+	if err != nil {
+		return nil, err
+	}
+	owner := j.ownerOf(request)
+	var owned = make([]*JobState, 0, len(list))
+	for _, job := range list {
+		if job.Owner == owner {
+			owned = append(owned, job.State())
+		}
+	}
+	return owned, nil
+}
+
+// Cancel running job: job is marked as canceled and context of method is canceled. Finished jobs are not changed.
+func (j *Jobs) Cancel(request *http.Request, id string) (*JobState, error) {
+	ctx := request.Context()
+	job, err := j.get(request, id)
+	if err != nil {
+		return nil, err
+	}
+	j.lock.Lock()
+	rj, ok := j.running[id]
+	j.lock.Unlock()
+	if !ok || job.Finished() || !rj.claim() {
+		return job.State(), nil
+	}
+	defer close(rj.done)
+	rj.cancel()
+	job.Status = JobCanceled
+	job.Updated = time.Now()
+	if err := j.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+	return job.State(), nil
+}
+
+// get job owned by caller. Jobs of other sessions are not found.
+func (j *Jobs) get(request *http.Request, id string) (*Job, error) {
+	job, err := j.store.Get(request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Owner != j.ownerOf(request) {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (j *Jobs) ownerOf(request *http.Request) string {
+	if j.session == nil {
+		return ""
+	}
+	return j.session(request)
+}
+
+// start job of method for caller. Function is called in background, canceled by cancel.
+func (j *Jobs) start(request *http.Request, method string, cancel context.CancelFunc, fn func() (json.RawMessage, error)) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &Job{ID: id, Owner: j.ownerOf(request), Method: method, Status: JobRunning, Created: now, Updated: now}
+	if err := j.store.Save(request.Context(), job); err != nil {
+		return nil, err
+	}
+	rj := &runningJob{cancel: cancel, done: make(chan struct{})}
+	j.lock.Lock()
+	j.running[id] = rj
+	j.lock.Unlock()
+
+	state := *job
+	go func() {
+		defer cancel()
+		result, err := j.run(fn)
+		if rj.claim() {
+			state.Status = JobDone
+			state.Result = result
+			if err != nil {
+				state.Status = JobFailed
+				state.Error = err.Error()
+			}
+			state.Updated = time.Now()
+			_ = j.store.Save(context.Background(), &state) // there is no one to report to
+			close(rj.done)
+		}
+		j.lock.Lock()
+		delete(j.running, id)
+		j.lock.Unlock()
+	}()
+	return job, nil
+}
+
+// run function and converts panic to error, since there is no HTTP server to recover it.
+func (j *Jobs) run(fn func() (json.RawMessage, error)) (result json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func newJobID() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// Async marks methods (by key in index: Go name or namespace.Name for namespaced methods) as asynchronous.
+// Call of such method is validated (payload and request-scoped parameters) and started in background:
+// response is 202 Accepted with [JobState] and result can be obtained by [Jobs]. Context of method keeps values of
+// request, but not its deadline and cancellation, and is canceled by [Jobs.Cancel].
+//
+// Methods with files, binary results or streams are always synchronous. Request-scoped parameters are resolved
+// before response, however method should not use *http.Request body after it.
+func Async(jobs *Jobs, methods ...string) Option {
+	return func(cfg *config) {
+		for _, method := range methods {
+			cfg.async[method] = jobs
+		}
+	}
+}
+
+// submit prepares arguments of asynchronous method and starts job. Errors have the same status codes as for
+// synchronous call.
+func (em *ExposedMethod) submit(receiver reflect.Value, request *http.Request, payload io.Reader) (*JobState, int, error) {
+	ctx, cancel := context.WithCancel(detached{request.Context()})
+	args, status, err := em.prepare(receiver, request.WithContext(ctx), payload, nil)
+	if err != nil {
+		cancel()
+		return nil, status, err
+	}
+	job, err := em.async.start(request, em.name, cancel, func() (json.RawMessage, error) {
+		response, err := em.call(args)
+		if err != nil || !em.hasResponse {
+			return nil, err
+		}
+		return json.Marshal(response)
+	})
+	if err != nil {
+		em.plan.put(args)
+		cancel()
+		return nil, http.StatusInternalServerError, err
+	}
+	return job.State(), 0, nil
+}
+
+// detached context keeps values of parent, but not deadline and cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (deadline time.Time, ok bool) { return }
+func (detached) Done() <-chan struct{}                   { return nil }
+func (detached) Err() error                              { return nil }
+func (d detached) Value(key any) any                     { return d.parent.Value(key) }
@@ -0,0 +1,302 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reddec/rpc"
+)
+
+type jobsAPI struct {
+	release chan struct{}
+}
+
+func (j *jobsAPI) Sum(ctx context.Context, a, b int) (int, error) {
+	select {
+	case <-j.release:
+		return a + b, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (j *jobsAPI) Fail(message string) error {
+	return errors.New(message)
+}
+
+func (j *jobsAPI) Block(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newJobsHandler(api *jobsAPI) (http.Handler, *rpc.Jobs) {
+	jobs := rpc.NewJobs(nil, func(r *http.Request) string {
+		return r.Header.Get("User")
+	})
+	index := rpc.NewRegistry().
+		Add("", api, rpc.Async(jobs, "Sum", "Fail", "Block")).
+		Add("jobs", jobs).
+		Index()
+	return rpc.Router(index), jobs
+}
+
+func callJob(t *testing.T, handler http.Handler, endpoint string, payload string, status int) *rpc.JobState {
+	t.Helper()
+	return callJobAs(t, handler, "", endpoint, payload, status)
+}
+
+func callJobAs(t *testing.T, handler http.Handler, user string, endpoint string, payload string, status int) *rpc.JobState {
+	t.Helper()
+	res := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/"+endpoint, strings.NewReader(payload))
+	request.Header.Set("User", user)
+	handler.ServeHTTP(res, request)
+	if res.Code != status {
+		t.Fatalf("%s: expected %d, got %d %s", endpoint, status, res.Code, res.Body.String())
+	}
+	var job rpc.JobState
+	if err := json.Unmarshal(res.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestAsync(t *testing.T) {
+	api := &jobsAPI{release: make(chan struct{})}
+	handler, _ := newJobsHandler(api)
+
+	job := callJob(t, handler, "sum", `[1, 2]`, http.StatusAccepted)
+	if job.ID == "" || job.Status != rpc.JobRunning || job.Method != "sum" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	state := callJob(t, handler, "jobs.get", `["`+job.ID+`"]`, http.StatusOK)
+	if state.Status != rpc.JobRunning {
+		t.Fatalf("job should be running, got %s", state.Status)
+	}
+
+	close(api.release)
+	state = callJob(t, handler, "jobs.wait", `["`+job.ID+`", 5]`, http.StatusOK)
+	if state.Status != rpc.JobDone || string(state.Result) != "3" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	failed := callJob(t, handler, "fail", `["oops"]`, http.StatusAccepted)
+	state = callJob(t, handler, "jobs.wait", `["`+failed.ID+`", 5]`, http.StatusOK)
+	if state.Status != rpc.JobFailed || state.Error != "oops" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/jobs.list", strings.NewReader(`[]`)))
+	var list []rpc.JobState
+	if err := json.Unmarshal(res.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != job.ID || list[1].ID != failed.ID {
+		t.Fatalf("unexpected list %s", res.Body.String())
+	}
+}
+
+func TestAsync_cancel(t *testing.T) {
+	handler, jobs := newJobsHandler(&jobsAPI{})
+
+	request := httptest.NewRequest(http.MethodPost, "/block", strings.NewReader(`[]`))
+	ctx, cancel := context.WithCancel(request.Context())
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, request.WithContext(ctx))
+	cancel() // request is finished, but job is not
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	var job rpc.JobState
+	if err := json.Unmarshal(res.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := jobs.Wait(httptest.NewRequest(http.MethodPost, "/", nil), job.ID, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != rpc.JobRunning {
+		t.Fatalf("job should not be canceled by request, got %s", state.Status)
+	}
+
+	state = callJob(t, handler, "jobs.cancel", `["`+job.ID+`"]`, http.StatusOK)
+	if state.Status != rpc.JobCanceled {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	state, err = jobs.Wait(httptest.NewRequest(http.MethodPost, "/", nil), job.ID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != rpc.JobCanceled {
+		t.Fatalf("canceled job should stay canceled, got %s", state.Status)
+	}
+}
+
+func TestAsync_badRequest(t *testing.T) {
+	handler, jobs := newJobsHandler(&jobsAPI{})
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/sum", strings.NewReader(`[1]`)))
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("invalid payload should be rejected before job, got %d", res.Code)
+	}
+	list, err := jobs.List(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("unexpected jobs %+v", list)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/jobs.get", strings.NewReader(`["missing"]`)))
+	if res.Code != http.StatusInternalServerError || res.Body.String() != rpc.ErrJobNotFound.Error() {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+}
+
+func TestAsync_owner(t *testing.T) {
+	handler, _ := newJobsHandler(&jobsAPI{})
+	job := callJobAs(t, handler, "alice", "block", `[]`, http.StatusAccepted)
+
+	for endpoint, payload := range map[string]string{
+		"jobs.get":    `["` + job.ID + `"]`,
+		"jobs.wait":   `["` + job.ID + `", 0]`,
+		"jobs.cancel": `["` + job.ID + `"]`,
+	} {
+		res := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/"+endpoint, strings.NewReader(payload))
+		request.Header.Set("User", "bob")
+		handler.ServeHTTP(res, request)
+		if res.Code != http.StatusInternalServerError || res.Body.String() != rpc.ErrJobNotFound.Error() {
+			t.Errorf("%s: job of other user should not be found, got %d %s", endpoint, res.Code, res.Body.String())
+		}
+	}
+
+	for user, count := range map[string]int{"alice": 1, "bob": 0} {
+		res := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/jobs.list", strings.NewReader(`[]`))
+		request.Header.Set("User", user)
+		handler.ServeHTTP(res, request)
+		var list []rpc.JobState
+		if err := json.Unmarshal(res.Body.Bytes(), &list); err != nil || len(list) != count {
+			t.Errorf("%s: expected %d jobs, got %s", user, count, res.Body.String())
+		}
+	}
+
+	state := callJobAs(t, handler, "alice", "jobs.cancel", `["`+job.ID+`"]`, http.StatusOK)
+	if state.Status != rpc.JobCanceled {
+		t.Fatalf("owner should cancel job, got %+v", state)
+	}
+}
+
+// jsonStore keeps jobs encoded to JSON, like stores backed by external databases.
+type jsonStore struct {
+	lock sync.Mutex
+	jobs map[string][]byte
+}
+
+func (js *jsonStore) Save(_ context.Context, job *rpc.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+	js.jobs[job.ID] = data
+	return nil
+}
+
+func (js *jsonStore) Get(_ context.Context, id string) (*rpc.Job, error) {
+	js.lock.Lock()
+	data, ok := js.jobs[id]
+	js.lock.Unlock()
+	if !ok {
+		return nil, rpc.ErrJobNotFound
+	}
+	var job rpc.Job
+	return &job, json.Unmarshal(data, &job)
+}
+
+func (js *jsonStore) List(ctx context.Context) ([]*rpc.Job, error) {
+	js.lock.Lock()
+	var ids []string
+	for id := range js.jobs {
+		ids = append(ids, id)
+	}
+	js.lock.Unlock()
+	var list []*rpc.Job
+	for _, id := range ids {
+		job, err := js.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	return list, nil
+}
+
+func TestAsync_jsonStore(t *testing.T) {
+	jobs := rpc.NewJobs(&jsonStore{jobs: make(map[string][]byte)}, func(r *http.Request) string {
+		return r.Header.Get("User")
+	})
+	handler := rpc.Router(rpc.NewRegistry().
+		Add("", &jobsAPI{}, rpc.Async(jobs, "Fail")).
+		Add("jobs", jobs).
+		Index())
+
+	res := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/fail", strings.NewReader(`["oops"]`))
+	request.Header.Set("User", "alice")
+	handler.ServeHTTP(res, request)
+	if res.Code != http.StatusAccepted || strings.Contains(res.Body.String(), "alice") {
+		t.Fatalf("owner should not be exposed: %d %s", res.Code, res.Body.String())
+	}
+	var job rpc.JobState
+	if err := json.Unmarshal(res.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+
+	state := callJobAs(t, handler, "alice", "jobs.wait", `["`+job.ID+`", 5]`, http.StatusOK)
+	if state.Status != rpc.JobFailed || state.Error != "oops" {
+		t.Fatalf("owner should get job after round-trip through JSON, got %+v", state)
+	}
+	if list, err := jobs.List(request); err != nil || len(list) != 1 {
+		t.Fatalf("owner should list job, got %v %v", list, err)
+	}
+	other := httptest.NewRequest(http.MethodPost, "/", nil)
+	other.Header.Set("User", "bob")
+	if _, err := jobs.Get(other, job.ID); !errors.Is(err, rpc.ErrJobNotFound) {
+		t.Fatalf("job of other user should not be found, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := rpc.MemoryStore(time.Millisecond)
+	ctx := context.Background()
+	old := time.Now().Add(-time.Second)
+
+	_ = store.Save(ctx, &rpc.Job{ID: "done", Status: rpc.JobDone, Created: old, Updated: old})
+	_ = store.Save(ctx, &rpc.Job{ID: "running", Status: rpc.JobRunning, Created: old, Updated: old})
+
+	if _, err := store.Get(ctx, "done"); !errors.Is(err, rpc.ErrJobNotFound) {
+		t.Fatalf("finished job should expire, got %v", err)
+	}
+	if _, err := store.Get(ctx, "running"); err != nil {
+		t.Fatalf("running job should not expire: %v", err)
+	}
+	list, _ := store.List(ctx)
+	if len(list) != 1 || list[0].ID != "running" {
+		t.Fatalf("unexpected list %+v", list)
+	}
+}
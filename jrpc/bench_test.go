@@ -0,0 +1,99 @@
+package jrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type benchAPI struct{}
+
+type benchUser struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+func (b *benchAPI) Double(_ context.Context, value int) int {
+	return value * 2
+}
+
+func (b *benchAPI) Save(_ context.Context, user benchUser) (*benchUser, error) {
+	return &user, nil
+}
+
+func benchDispatcher() Option {
+	return Static(map[string]Direct[*benchAPI]{
+		"Double": func(receiver *benchAPI, request *http.Request, payload json.RawMessage) (any, error) {
+			var arg0 int
+			if err := DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Double(request.Context(), arg0), nil
+		},
+		"Save": func(receiver *benchAPI, request *http.Request, payload json.RawMessage) (any, error) {
+			var arg0 benchUser
+			if err := DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Save(request.Context(), arg0)
+		},
+	})
+}
+
+const benchUserPayload = `{"id": 1, "name": "alice", "email": "alice@example.com", "tags": ["admin", "dev"]}`
+
+// discardWriter is reusable response writer without recording.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardWriter) WriteHeader(status int)      { d.status = status }
+
+func benchHandler(b *testing.B, handler http.Handler, endpoint string, payload string) {
+	b.Helper()
+	request := httptest.NewRequest(http.MethodPost, endpoint, nil)
+	body := bytes.NewReader([]byte(payload))
+	writer := &discardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body.Reset([]byte(payload))
+		request.Body = io.NopCloser(body)
+		for k := range writer.header {
+			delete(writer.header, k)
+		}
+		handler.ServeHTTP(writer, request)
+		if writer.status != http.StatusOK {
+			b.Fatal(writer.status)
+		}
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	handler := New(&benchAPI{})
+	b.Run("scalar", func(b *testing.B) {
+		benchHandler(b, handler, "/Double", `21`)
+	})
+	b.Run("object", func(b *testing.B) {
+		benchHandler(b, handler, "/Save", benchUserPayload)
+	})
+}
+
+func BenchmarkStatic(b *testing.B) {
+	handler := New(&benchAPI{}, benchDispatcher())
+	b.Run("scalar", func(b *testing.B) {
+		benchHandler(b, handler, "/Double", `21`)
+	})
+	b.Run("object", func(b *testing.B) {
+		benchHandler(b, handler, "/Save", benchUserPayload)
+	})
+}
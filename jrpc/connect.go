@@ -0,0 +1,194 @@
+package jrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/rpc"
+)
+
+// Connect error codes.
+const (
+	CodeCanceled           = "canceled"
+	CodeUnknown            = "unknown"
+	CodeInvalidArgument    = "invalid_argument"
+	CodeDeadlineExceeded   = "deadline_exceeded"
+	CodeNotFound           = "not_found"
+	CodeAlreadyExists      = "already_exists"
+	CodePermissionDenied   = "permission_denied"
+	CodeResourceExhausted  = "resource_exhausted"
+	CodeFailedPrecondition = "failed_precondition"
+	CodeAborted            = "aborted"
+	CodeOutOfRange         = "out_of_range"
+	CodeUnimplemented      = "unimplemented"
+	CodeInternal           = "internal"
+	CodeUnavailable        = "unavailable"
+	CodeDataLoss           = "data_loss"
+	CodeUnauthenticated    = "unauthenticated"
+)
+
+var connectStatus = map[string]int{
+	CodeCanceled:           499,
+	CodeUnknown:            http.StatusInternalServerError,
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeFailedPrecondition: http.StatusBadRequest,
+	CodeAborted:            http.StatusConflict,
+	CodeOutOfRange:         http.StatusBadRequest,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeDataLoss:           http.StatusInternalServerError,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// ConnectError wraps error with Connect code (see Code* constants), which is reported to Connect clients.
+// Errors without code are reported as unknown.
+//
+//	return nil, jrpc.ConnectError(jrpc.CodeNotFound, err)
+func ConnectError(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+type codedError struct {
+	code string
+	err  error
+}
+
+func (ce *codedError) Error() string {
+	return ce.err.Error()
+}
+
+func (ce *codedError) Unwrap() error {
+	return ce.err
+}
+
+// payloadError is returned if payload can not be parsed.
+type payloadError struct {
+	err error
+}
+
+func (pe *payloadError) Error() string {
+	return "parse: " + pe.err.Error()
+}
+
+func (pe *payloadError) Unwrap() error {
+	return pe.err
+}
+
+// Connect creates handler which accepts Connect protocol unary requests with JSON codec:
+// POST /<service>/<Method> with application/json payload, where service is fully-qualified name
+// (for example acme.calc.v1.CalcService) and method is endpoint name (or alias).
+//
+//	http.Handle("/acme.calc.v1.CalcService/", api.Connect("acme.calc.v1.CalcService"))
+//
+// Handler validates Connect-Protocol-Version (if set) and honors Connect-Timeout-Ms. Methods without result
+// return empty object. Errors are returned as Connect error JSON ({"code": "...", "message": "..."}) with matching
+// HTTP status: invalid payload is invalid_argument, unknown method is unimplemented, timeout is deadline_exceeded,
+// codes of method errors can be set by [ConnectError] (others are unknown). Methods with files or binary
+// results are not supported.
+func (srv *RPC) Connect(service string) http.Handler {
+	prefix := "/" + strings.Trim(service, "/") + "/"
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.Header().Set("Allow", http.MethodPost)
+			writeConnectError(writer, CodeUnimplemented, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeConnectError(writer, CodeUnimplemented, "only application/json is supported", http.StatusUnsupportedMediaType)
+			return
+		}
+		if version := request.Header.Get("Connect-Protocol-Version"); version != "" && version != "1" {
+			writeConnectError(writer, CodeInvalidArgument, "unsupported Connect-Protocol-Version "+version, 0)
+			return
+		}
+		if value := request.Header.Get("Connect-Timeout-Ms"); value != "" {
+			timeout, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || timeout <= 0 {
+				writeConnectError(writer, CodeInvalidArgument, "invalid Connect-Timeout-Ms", 0)
+				return
+			}
+			ctx, cancel := context.WithTimeout(request.Context(), time.Duration(timeout)*time.Millisecond)
+			defer cancel()
+			request = request.WithContext(ctx)
+		}
+
+		m, ok := srv.methods[strings.TrimPrefix(request.URL.Path, prefix)]
+		if !ok || !strings.HasPrefix(request.URL.Path, prefix) || len(m.fileTypes) > 0 || m.binary {
+			writeConnectError(writer, CodeUnimplemented, "unknown method "+request.URL.Path, 0)
+			return
+		}
+
+		var input json.RawMessage
+		if m.hasArg {
+			if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+				writeConnectError(writer, CodeInvalidArgument, err.Error(), 0)
+				return
+			}
+		}
+
+		meta := rpc.NewResponse()
+		request = request.WithContext(rpc.WithResponse(request.Context(), meta))
+		result, err := m.call(request, input, nil)
+		var output json.RawMessage
+		if err == nil {
+			output = json.RawMessage("{}")
+			if m.hasResponse {
+				output, err = json.Marshal(result)
+			}
+		}
+		meta.Apply(writer)
+		if err != nil {
+			writeConnectError(writer, connectCode(request.Context(), err), err.Error(), 0)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write(output)
+	})
+}
+
+func connectCode(ctx context.Context, err error) string {
+	var coded *codedError
+	var payload *payloadError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &payload):
+		return CodeInvalidArgument
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return CodeDeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	default:
+		return CodeUnknown
+	}
+}
+
+// writeConnectError writes error JSON. Status derived from code if not set.
+func writeConnectError(writer http.ResponseWriter, code string, message string, status int) {
+	if status == 0 {
+		status = connectStatus[code]
+	}
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	data, _ := json.Marshal(struct {
+		Code    string `json:"code"`
+		Message string `json:"message,omitempty"`
+	}{Code: code, Message: message})
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_, _ = writer.Write(data)
+}
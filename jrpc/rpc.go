@@ -2,13 +2,24 @@
 package jrpc
 
 import (
-	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/internal/decode"
+	"github.com/reddec/rpc/internal/download"
+	"github.com/reddec/rpc/internal/inject"
+	"github.com/reddec/rpc/internal/upload"
+	"github.com/reddec/rpc/naming"
 )
 
 //go:embed index.html
@@ -44,12 +55,31 @@ var indexPage []byte
 //	f(payload) -> error
 //	f(payload) -> (v, error)
 //
+// Request-scoped parameters (context.Context, *http.Request, http.Header and types registered by [Inject])
+// can be placed at any position and are not considered as payload:
+//
+//	f(ctx, headers, payload, principal) -> (v, error)
+//
+// Files (io.Reader, *multipart.FileHeader or [rpc.File]) can be placed at any position and are filled from
+// multipart/form-data request: part "args" contains payload, parts "file0", "file1", ... contain files in order of
+// arguments. See [rpc.Index] for details about streaming.
+//
+//	f(ctx, payload, content io.Reader) -> (v, error)
+//
+// Results implementing io.Reader or io.WriterTo (for example io.ReadCloser or [rpc.Blob]) are streamed as raw content
+// instead of JSON. See [rpc.Index] for details about downloads.
+//
+//	f(ctx, payload) -> (*rpc.Blob, error)
+//
+// Endpoint name is derived from method name by [naming.Strategy] (default is [naming.AsIs]), see [Naming] and [Alias].
+// New panics if endpoint names (including aliases) of different methods collide or alias refers to unknown method.
+//
 // See [RPC.ServeHTTP] for details.
 func New(object any, options ...Option) *RPC {
+	cfg := newConfig(options)
 	value := reflect.ValueOf(object)
 	t := value.Type()
 	errorInterface := reflect.TypeOf((*error)(nil)).Elem()
-	ctxInterface := reflect.TypeOf((*context.Context)(nil)).Elem()
 
 	res := make(map[string]*exposedMethod)
 	n := t.NumMethod()
@@ -73,18 +103,34 @@ func New(object any, options ...Option) *RPC {
 
 		hasResponse := out == 1 && !hasError || out == 2
 
-		// check input
-		hasContext := args > 1 && method.Type.In(1).Implements(ctxInterface)
-		hasArg := hasContext && args == 3 || !hasContext && args == 2
-
-		// too many args
-		if args > 3 { // receiver + ctx + arg
-			continue
+		// check input: request-scoped parameters are resolved by providers, files are taken from multipart request,
+		// at most one payload argument
+		var providers = make([]inject.Provider, args)
+		var fileIndexes []int
+		var fileTypes []reflect.Type
+		var argIndex int
+		var custom bool // has parameters resolved by custom providers
+		for arg := 1; arg < args; arg++ {
+			if provider, ok := cfg.providers[method.Type.In(arg)]; ok {
+				providers[arg] = provider
+				custom = custom || cfg.custom[method.Type.In(arg)]
+				continue
+			}
+			if upload.IsFile(method.Type.In(arg)) {
+				fileIndexes = append(fileIndexes, arg)
+				fileTypes = append(fileTypes, method.Type.In(arg))
+				continue
+			}
+			if argIndex != 0 {
+				argIndex = -1 // too many payload args
+				break
+			}
+			argIndex = arg
 		}
-		// for two args (+receiver), the first one must be context
-		if !hasContext && args == 3 {
+		if argIndex < 0 {
 			continue
 		}
+		hasArg := argIndex > 0
 
 		// build
 		var responseType reflect.Type
@@ -94,37 +140,176 @@ func New(object any, options ...Option) *RPC {
 
 		var argType reflect.Type
 		if hasArg {
-			argType = method.Type.In(args - 1)
+			argType = method.Type.In(argIndex)
 		}
 
 		em := &exposedMethod{
-			hasContext:  hasContext,
+			name:        cfg.naming(method.Name),
+			description: cfg.description(t, method.Name),
 			hasArg:      hasArg,
+			argIndex:    argIndex,
+			providers:   providers,
+			fileIndexes: fileIndexes,
+			fileTypes:   fileTypes,
 			hasError:    hasError,
 			hasResponse: hasResponse,
+			binary:      hasResponse && download.IsBinary(responseType),
 			obj:         value,
 			argType:     argType,
 			retType:     responseType,
 			method:      method,
+			direct:      directCall(cfg, t, method, fileIndexes, custom),
+			uploads:     cfg.uploads,
+		}
+		em.pool.New = func() any {
+			values := make([]reflect.Value, args)
+			return &values
 		}
 
 		handler := em
 		res[method.Name] = handler
 	}
 
-	schema, err := json.Marshal(generateOpenAPI(res, options...))
+	routes := make(map[string]*exposedMethod)
+	owners := make(map[string]string) // endpoint name -> Go name of method
+	names := make([]string, 0, len(res))
+	for name := range res {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		handler := res[name]
+		for _, endpoint := range append([]string{handler.name}, cfg.aliases[name]...) {
+			if other, ok := owners[endpoint]; ok && other != name {
+				panic("jrpc: endpoint " + endpoint + " of " + name + " conflicts with " + other)
+			}
+			owners[endpoint] = name
+			routes[endpoint] = handler
+		}
+	}
+	for name, aliases := range cfg.aliases {
+		if _, ok := res[name]; !ok {
+			panic("jrpc: alias " + strings.Join(aliases, ", ") + " refers to unknown method " + name)
+		}
+	}
+
+	schema, err := json.Marshal(cfg.schema.build(res))
 	if err != nil {
 		panic(err) // should never happen
 	}
 	return &RPC{
 		schema:  schema,
-		methods: res,
+		methods: routes,
+		tools:   cfg.schema.tools(res),
+	}
+}
+
+// Option configures methods indexing and schema creation.
+type Option func(cfg *config)
+
+type config struct {
+	naming       naming.Strategy
+	aliases      map[string][]string
+	descriptions map[string]string
+	providers    inject.Providers
+	custom       map[reflect.Type]bool // types registered by Inject
+	static       map[reflect.Type]map[string]direct
+	schema       *schemaBuilder
+	uploads      upload.Limits // see MaxUploadArgs and MaxUploadSpool
+}
+
+func newConfig(options []Option) *config {
+	cfg := &config{
+		naming:       naming.AsIs,
+		aliases:      make(map[string][]string),
+		descriptions: make(map[string]string),
+		providers:    inject.New(),
+		custom:       make(map[reflect.Type]bool),
+		static:       make(map[reflect.Type]map[string]direct),
+		schema:       newSchemaBuilder(),
+		uploads:      upload.Limits{Args: rpc.DefaultMaxUploadArgs},
+	}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// description of method: explicit or from docs (by Go name or by receiver type and Go name).
+func (cfg *config) description(receiver reflect.Type, method string) string {
+	if description, ok := cfg.descriptions[method]; ok {
+		return description
+	}
+	if description, ok := cfg.schema.docs[method]; ok {
+		return description
+	}
+	if receiver.Kind() == reflect.Ptr {
+		receiver = receiver.Elem()
+	}
+	if receiver.Name() == "" {
+		return ""
+	}
+	return cfg.schema.docs[receiver.PkgPath()+"."+receiver.Name()+"."+method]
+}
+
+// Naming sets strategy which converts method name to endpoint name. Default is [naming.AsIs] (case-sensitive).
+func Naming(strategy naming.Strategy) Option {
+	return func(cfg *config) {
+		cfg.naming = strategy
+	}
+}
+
+// Alias exposes method (by Go name) under additional endpoint names (used as-is).
+// Useful to keep old endpoint after renaming method. New panics if method is not exposed or alias collides with
+// endpoint of another method.
+func Alias(method string, aliases ...string) Option {
+	return func(cfg *config) {
+		cfg.aliases[method] = append(cfg.aliases[method], aliases...)
+	}
+}
+
+// MaxUploadArgs limits size (in bytes) of "args" part of multipart request, which is read into memory.
+// Larger parts are rejected by 413 Request Entity Too Large. Zero means no limit. Default is [rpc.DefaultMaxUploadArgs].
+func MaxUploadArgs(size int64) Option {
+	return func(cfg *config) {
+		cfg.uploads.Args = size
+	}
+}
+
+// MaxUploadSpool limits size (in bytes) of each file stored to temporary file: all files except the last one
+// when streaming, see [rpc.Index]. Larger files are rejected by 413 Request Entity Too Large. Default is zero
+// (no limit), so temporary files are limited only by disk space.
+func MaxUploadSpool(size int64) Option {
+	return func(cfg *config) {
+		cfg.uploads.Spool = size
+	}
+}
+
+// Describe sets description of method (by Go name), which is used in schema (the first line as summary) and as tool
+// description (see [RPC.Tools]). Doc comments are not available in runtime, but can be generated, see [Docs].
+func Describe(method string, description string) Option {
+	return func(cfg *config) {
+		cfg.descriptions[method] = description
+	}
+}
+
+// Inject registers provider of request-scoped parameter of type T. Parameters of such type (at any position)
+// are resolved by provider on each request and are not considered as payload (and therefore excluded from schema).
+// Provider error causes 500 Internal Server Error.
+//
+// By default, context.Context (request context), *http.Request and http.Header (request headers) are injected.
+func Inject[T any](provider func(r *http.Request) (T, error)) Option {
+	t, p := inject.Typed(provider)
+	return func(cfg *config) {
+		cfg.providers[t] = p
+		cfg.custom[t] = true
 	}
 }
 
 type RPC struct {
 	schema  []byte
 	methods map[string]*exposedMethod
+	tools   []Tool
 }
 
 // ServeHTTP accepts POST request with JSON payload (Content-Type header is NOT checked).
@@ -132,10 +317,13 @@ type RPC struct {
 // - only POST is allowed, otherwise 405 Method Not Allowed will be returned
 // - in case of exported method is not accepting payload, payload will be ignored
 // - in case of error during decoding payload, 400 Bad Request returned with plain text details
-// - in case of unknown method (case-sensitive), 404 Not Found returned
+// - in case part of multipart request exceeds limit (see [MaxUploadArgs]), 413 Request Entity Too Large returned
+// - in case of unknown method (endpoint name or alias, case-sensitive), 404 Not Found returned
 // - in case of error during call, 500 Internal Server Error returned with plain text details
 // - in case of exported method is not returning value, 204 No Content returned, otherwise 200 OK and JSON (with proper headers)
-func (rpc *RPC) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+// - binary results (io.Reader, io.WriterTo or [rpc.Blob]) are streamed as raw content, seekable content supports Range requests
+// - headers, cookies and custom success status can be set by method using [rpc.ResponseFrom]
+func (srv *RPC) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	method := path.Base(request.URL.Path)
 	if request.Method == http.MethodGet {
 		if method == "" || method == "/" {
@@ -145,12 +333,12 @@ func (rpc *RPC) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		}
 		if method == "swagger.json" { // schema
 			writer.Header().Set("Content-Type", "application/json")
-			_, _ = writer.Write(rpc.schema)
+			_, _ = writer.Write(srv.schema)
 			return
 		}
 	}
 
-	m, ok := rpc.methods[method]
+	m, ok := srv.methods[method]
 	if request.Method != http.MethodPost {
 		writer.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -160,17 +348,63 @@ func (rpc *RPC) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	var input json.RawMessage
-	if m.hasArg {
-		if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+	meta := rpc.NewResponse()
+	request = request.WithContext(rpc.WithResponse(request.Context(), meta))
+
+	var result any
+	var err error
+	switch {
+	case len(m.fileTypes) > 0:
+		if !upload.IsMultipart(request) {
+			badRequest(writer, "multipart/form-data expected")
+			return
+		}
+		input, files, cleanup, readErr := upload.Read(request, m.fileTypes, m.uploads)
+		defer cleanup()
+		if errors.Is(readErr, upload.ErrTooLarge) {
 			writer.Header().Set("Content-Type", "text/plain")
-			writer.WriteHeader(http.StatusBadRequest)
-			_, _ = writer.Write([]byte(err.Error()))
+			writer.WriteHeader(http.StatusRequestEntityTooLarge)
+			_, _ = writer.Write([]byte(readErr.Error()))
+			return
+		}
+		if readErr != nil {
+			badRequest(writer, readErr.Error())
+			return
+		}
+		if m.hasArg && input == nil {
+			input = json.RawMessage("null")
+		}
+		result, err = m.call(request, input, files)
+	case m.hasArg && m.direct == nil:
+		// decode directly into argument
+		arg, readErr := m.read(request.Body)
+		var pe *payloadError
+		if readErr != nil && !errors.As(readErr, &pe) {
+			badRequest(writer, readErr.Error())
 			return
 		}
+		if err = readErr; err == nil {
+			result, err = m.invoke(request, arg, nil)
+		}
+	case m.hasArg:
+		var input json.RawMessage
+		if readErr := json.NewDecoder(request.Body).Decode(&input); readErr != nil {
+			badRequest(writer, readErr.Error())
+			return
+		}
+		result, err = m.call(request, input, nil)
+	default:
+		result, err = m.call(request, nil, nil)
 	}
 
-	output, err := m.call(request.Context(), input)
+	var output json.RawMessage
+	if err == nil && m.hasResponse && !m.binary {
+		output, err = json.Marshal(result)
+		if err != nil {
+			err = fmt.Errorf("encode result: %w", err)
+		}
+	}
+	meta.Apply(writer)
 	if err != nil {
 		writer.Header().Set("Content-Type", "text/plain")
 		writer.WriteHeader(http.StatusInternalServerError)
@@ -179,57 +413,129 @@ func (rpc *RPC) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	if !m.hasResponse {
-		writer.WriteHeader(http.StatusNoContent)
+		writer.WriteHeader(meta.StatusOr(http.StatusNoContent))
+		return
+	}
+
+	if m.binary {
+		download.Write(writer, request, result, meta.Status())
 		return
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(meta.StatusOr(http.StatusOK))
 	_, _ = writer.Write(output)
 }
 
+func badRequest(writer http.ResponseWriter, message string) {
+	writer.Header().Set("Content-Type", "text/plain")
+	writer.WriteHeader(http.StatusBadRequest)
+	_, _ = writer.Write([]byte(message))
+}
+
 type exposedMethod struct {
-	hasContext  bool
+	name        string
+	description string
 	hasArg      bool
+	argIndex    int               // position of payload in method signature
+	providers   []inject.Provider // by position in method signature, nil for payload argument
+	fileIndexes []int             // positions of files in method signature
+	fileTypes   []reflect.Type
 	hasError    bool
 	hasResponse bool
+	binary      bool          // response is streamed as raw content
+	uploads     upload.Limits // limits of multipart parts, see MaxUploadArgs
 
 	obj     reflect.Value
 	argType reflect.Type
 	retType reflect.Type
 	method  reflect.Method
+	direct  direct    // statically typed call, see Static
+	pool    sync.Pool // *[]reflect.Value of method arguments
 }
 
-func (m *exposedMethod) call(ctx context.Context, data json.RawMessage) (json.RawMessage, error) {
-	var args = make([]reflect.Value, 0, 3)
-	args = append(args, m.obj)
-	if m.hasContext {
-		args = append(args, reflect.ValueOf(ctx))
+func (m *exposedMethod) call(request *http.Request, data json.RawMessage, files []reflect.Value) (any, error) {
+	if m.direct != nil {
+		if !m.hasArg {
+			data = nil
+		}
+		result, err := m.direct(m.obj, request, data)
+		if err != nil {
+			if closer, ok := result.(io.Closer); m.binary && ok {
+				_ = closer.Close()
+			}
+			return nil, err
+		}
+		return result, nil
 	}
+	var arg reflect.Value
 	if m.hasArg {
 		v, err := m.parseArg(data)
 		if err != nil {
-			return nil, fmt.Errorf("parse: %w", err)
+			return nil, &payloadError{err: err}
+		}
+		arg = v
+	}
+	return m.invoke(request, arg, files)
+}
+
+// invoke method by reflection with decoded payload. Arguments are taken from pool.
+func (m *exposedMethod) invoke(request *http.Request, arg reflect.Value, files []reflect.Value) (any, error) {
+	values := m.pool.Get().(*[]reflect.Value)
+	defer func() {
+		for i := range *values {
+			(*values)[i] = reflect.Value{}
 		}
-		args = append(args, v)
+		m.pool.Put(values)
+	}()
+
+	args := *values
+	args[0] = m.obj
+	for i, index := range m.fileIndexes {
+		args[index] = files[i]
+	}
+	if m.hasArg {
+		args[m.argIndex] = arg
+	}
+	for i, provider := range m.providers {
+		if provider == nil {
+			continue
+		}
+		v, err := provider(request)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
 	}
 	output := m.method.Func.Call(args)
-	responseValues := toAny(output)
 
 	if m.hasError {
-		if v := responseValues[len(responseValues)-1]; v != nil {
-			return nil, v.(error)
+		if v := output[len(output)-1]; v.Kind() != reflect.Interface || !v.IsNil() {
+			if closer, ok := output[0].Interface().(io.Closer); m.binary && ok {
+				_ = closer.Close()
+			}
+			return nil, v.Interface().(error)
 		}
 	}
 
 	if !m.hasResponse {
 		return nil, nil
 	}
+	return output[0].Interface(), nil
+}
 
-	res, err := json.Marshal(responseValues[0])
-	if err != nil {
-		return nil, fmt.Errorf("encode result: %w", err)
+// read payload directly from reader into argument. Malformed JSON is returned as is, decoding error is
+// returned as payloadError (the same as by parseArg).
+func (m *exposedMethod) read(reader io.Reader) (reflect.Value, error) {
+	source := &decode.Source{Reader: reader}
+	argValue := reflect.New(m.argType)
+	if err := json.NewDecoder(source).Decode(argValue.Interface()); err != nil {
+		if source.Malformed(err) {
+			return reflect.Value{}, err
+		}
+		return reflect.Value{}, &payloadError{err: fmt.Errorf("decode payload: %w", err)}
 	}
-	return res, nil
+	return argValue.Elem(), nil
 }
 
 func (m *exposedMethod) parseArg(data json.RawMessage) (reflect.Value, error) {
@@ -239,11 +545,3 @@ func (m *exposedMethod) parseArg(data json.RawMessage) (reflect.Value, error) {
 	}
 	return argValue.Elem(), nil
 }
-
-func toAny(values []reflect.Value) []any {
-	var ans = make([]any, len(values))
-	for i, v := range values {
-		ans[i] = v.Interface()
-	}
-	return ans
-}
@@ -3,9 +3,19 @@ package jrpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/naming"
 )
 
 type Calc struct{}
@@ -34,6 +44,45 @@ func (c *Calc) Noop() {
 
 }
 
+func (c *Calc) Store(ctx context.Context, value int) {
+	response := rpc.ResponseFrom(ctx)
+	response.Header().Set("Location", "/values/"+strconv.Itoa(value))
+	response.SetStatus(http.StatusCreated)
+}
+
+func (c *Calc) Scale(_ context.Context, headers http.Header, value []int, factor int) []int {
+	var res []int
+	for _, v := range value {
+		res = append(res, v*factor+len(headers.Get("X-Offset")))
+	}
+	return res
+}
+
+func (c *Calc) Upload(prefix string, content io.Reader) (string, error) {
+	data, err := io.ReadAll(content)
+	return prefix + string(data), err
+}
+
+func (c *Calc) Export(value []int) *rpc.Blob {
+	var buf bytes.Buffer
+	for _, v := range value {
+		buf.WriteString(strconv.Itoa(v) + "\n")
+	}
+	return &rpc.Blob{Reader: bytes.NewReader(buf.Bytes()), Name: "values.txt", ContentType: "text/plain"}
+}
+
+func (c *Calc) Find(id int) (string, error) {
+	if id != 1 {
+		return "", ConnectError(CodeNotFound, errors.New("not found"))
+	}
+	return "one", nil
+}
+
+func (c *Calc) Sleep(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
 func (c *Calc) Greet(name struct {
 	Name   string `json:"name"`
 	Prefix string `json:"prefix"`
@@ -96,6 +145,19 @@ func TestNew(t *testing.T) {
 		}
 	})
 
+	t.Run("response metadata", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/Store", bytes.NewBufferString("42"))
+		res := httptest.NewRecorder()
+
+		r.ServeHTTP(res, req)
+		if res.Code != http.StatusCreated {
+			t.Fatal(res.Code, res.Body.String())
+		}
+		if v := res.Header().Get("Location"); v != "/values/42" {
+			t.Fatal(v)
+		}
+	})
+
 	t.Run("landing page", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		res := httptest.NewRecorder()
@@ -133,3 +195,404 @@ func TestNew(t *testing.T) {
 		t.Log(res.Body.String())
 	})
 }
+
+func TestInject(t *testing.T) {
+	r := New(&Calc{}, Inject(func(r *http.Request) (int, error) {
+		return strconv.Atoi(r.Header.Get("X-Factor"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/Scale", bytes.NewBufferString("[1,2,3]"))
+	req.Header.Set("X-Factor", "2")
+	req.Header.Set("X-Offset", "1")
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	if res.Body.String() != "[3,5,7]" {
+		t.Fatal(res.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/Scale", bytes.NewBufferString("[1,2,3]"))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusInternalServerError {
+		t.Fatal(res.Code, res.Body.String())
+	}
+
+	if _, ok := New(&Calc{}).methods["Scale"]; ok {
+		t.Error("method with two payload arguments should be skipped")
+	}
+}
+
+func TestNaming(t *testing.T) {
+	r := New(&Calc{}, Naming(naming.Kebab), Alias("SumCtx", "total"))
+
+	for _, path := range []string{"/sum-ctx", "/total"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString("[1,2,3]"))
+		res := httptest.NewRecorder()
+
+		r.ServeHTTP(res, req)
+		if res.Code != http.StatusOK {
+			t.Fatal(path, res.Code, res.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/SumCtx", bytes.NewBufferString("[1,2,3]"))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusNotFound {
+		t.Fatal(res.Code, res.Body.String())
+	}
+}
+
+func TestNaming_conflicts(t *testing.T) {
+	cases := map[string]struct {
+		options  []Option
+		messages []string
+	}{
+		"naming":        {[]Option{Naming(naming.Lower)}, []string{"sum", "Sum", "SUM"}},
+		"alias":         {[]Option{Alias("Hi", "Sum")}, []string{"Sum", "Hi"}},
+		"unknown alias": {[]Option{Alias("Hello", "greet")}, []string{"greet", "Hello"}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var message string
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						message = fmt.Sprint(r)
+					}
+				}()
+				New(&collidingCalc{}, c.options...)
+			}()
+			if message == "" {
+				t.Fatal("should panic")
+			}
+			for _, part := range c.messages {
+				if !strings.Contains(message, part) {
+					t.Errorf("message %q should contain %q", message, part)
+				}
+			}
+		})
+	}
+}
+
+type collidingCalc struct{}
+
+func (c *collidingCalc) Sum(values []int) int { return 0 }
+func (c *collidingCalc) SUM(values []int) int { return 0 }
+func (c *collidingCalc) Hi() string           { return "hi" }
+
+func TestUpload(t *testing.T) {
+	r := New(&Calc{})
+
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	_ = form.WriteField("args", `"file:"`)
+	w, _ := form.CreateFormFile("file0", "doc.txt")
+	_, _ = w.Write([]byte("hello"))
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/Upload", &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusOK || res.Body.String() != `"file:hello"` {
+		t.Fatal(res.Code, res.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/Upload", bytes.NewBufferString(`"file:"`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Fatal(res.Code, res.Body.String())
+	}
+
+	if !bytes.Contains(r.schema, []byte(`"multipart/form-data"`)) {
+		t.Fatal("multipart body should be described")
+	}
+
+	buf.Reset()
+	form = multipart.NewWriter(&buf)
+	_ = form.WriteField("args", `"`+strings.Repeat("x", 32)+`"`)
+	w, _ = form.CreateFormFile("file0", "doc.txt")
+	_, _ = w.Write([]byte("hello"))
+	_ = form.Close()
+	req = httptest.NewRequest(http.MethodPost, "/Upload", &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	res = httptest.NewRecorder()
+	New(&Calc{}, MaxUploadArgs(16)).ServeHTTP(res, req)
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(res.Code, res.Body.String())
+	}
+}
+
+func TestDownload(t *testing.T) {
+	r := New(&Calc{})
+
+	req := httptest.NewRequest(http.MethodPost, "/Export", bytes.NewBufferString("[1,2]"))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusOK || res.Body.String() != "1\n2\n" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	if h := res.Header().Get("Content-Type"); h != "text/plain" {
+		t.Fatal(h)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/Export", bytes.NewBufferString("[1,2]"))
+	req.Header.Set("Range", "bytes=2-")
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusPartialContent || res.Body.String() != "2\n" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+
+	if !bytes.Contains(r.schema, []byte(`"application/octet-stream"`)) {
+		t.Fatal("binary response should be described")
+	}
+}
+
+func TestServe(t *testing.T) {
+	r := New(&Calc{})
+	input := `{"id": 1, "method": "Sum", "args": [1, 2, 3]}` + "\n"
+	var output bytes.Buffer
+	if err := r.Serve(context.Background(), bytes.NewBufferString(input), &output, rpc.Lines); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != `{"id":1,"result":6}`+"\n" {
+		t.Fatal(output.String())
+	}
+}
+
+func TestTools(t *testing.T) {
+	r := New(&Calc{}, Describe("Greet", "Say hello"))
+	tools := r.Tools()
+	var greet *Tool
+	for i := range tools {
+		if tools[i].Name == "Upload" || tools[i].Name == "Export" {
+			t.Error(tools[i].Name, "should be excluded")
+		}
+		if tools[i].Name == "Greet" {
+			greet = &tools[i]
+		}
+	}
+	if greet == nil || greet.Description != "Say hello" {
+		t.Fatal("described tool expected")
+	}
+	if !bytes.Contains(greet.InputSchema, []byte(`"type":"object"`)) || bytes.Contains(greet.InputSchema, []byte("components")) {
+		t.Error(string(greet.InputSchema))
+	}
+
+	out, err := r.CallTool(httptest.NewRequest(http.MethodPost, "/", nil), "Sum", []byte(`{"payload": [1, 2]}`))
+	if err != nil || string(out) != "3" {
+		t.Fatal(string(out), err)
+	}
+	if !bytes.Contains(r.schema, []byte(`"summary":"Say hello"`)) {
+		t.Error("summary should be set")
+	}
+}
+
+func TestDocs(t *testing.T) {
+	r := New(&Calc{}, Describe("Hi", "Explicit"), Docs(map[string]string{
+		"Hi":                                    "From docs",
+		"github.com/reddec/rpc/jrpc.Calc.Greet": "Greet user.\n\nName is required.", // generated by rpc-gen
+	}))
+	var descriptions = make(map[string]string)
+	for _, tool := range r.Tools() {
+		descriptions[tool.Name] = tool.Description
+	}
+	if descriptions["Hi"] != "Explicit" || descriptions["Greet"] != "Greet user.\n\nName is required." {
+		t.Fatal(descriptions)
+	}
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/swagger.json", nil))
+	var doc struct {
+		Paths map[string]struct {
+			Post struct {
+				Summary     string `json:"summary"`
+				Description string `json:"description"`
+			} `json:"post"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	greet := doc.Paths["/Greet"].Post
+	if greet.Summary != "Greet user." || greet.Description != descriptions["Greet"] {
+		t.Fatal(greet)
+	}
+	if hi := doc.Paths["/Hi"].Post; hi.Summary != "Explicit" || hi.Description != "" {
+		t.Fatal(hi)
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	r := New(&Calc{}, Security("token", BearerAuth(""), "Sum"), Security("key", HeaderAuth("X-Key")), Public("Hi"))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/swagger.json", nil))
+	var doc struct {
+		Security []map[string][]string `json:"security"`
+		Paths    map[string]struct {
+			Post struct {
+				Security []map[string][]string `json:"security"`
+			} `json:"post"`
+		} `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Security) != 1 || doc.Security[0]["key"] == nil || doc.Components.SecuritySchemes["token"].Scheme != "bearer" {
+		t.Fatal(res.Body.String())
+	}
+	if sum := doc.Paths["/Sum"].Post.Security; len(sum) != 1 || sum[0]["token"] == nil {
+		t.Error(sum)
+	}
+	if hi := doc.Paths["/Hi"].Post.Security; len(hi) != 1 || len(hi[0]) != 0 {
+		t.Error(hi)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	handler := New(&Calc{}).Connect("acme.calc.v1.CalcService")
+	call := func(path string, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Connect-Protocol-Version", "1")
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		body    string
+		headers []string
+		status  int
+		output  string
+	}{
+		{"ok", "/acme.calc.v1.CalcService/Find", "1", nil, http.StatusOK, `"one"`},
+		{"no result", "/acme.calc.v1.CalcService/Noop", "{}", nil, http.StatusOK, `{}`},
+		{"coded error", "/acme.calc.v1.CalcService/Find", "2", nil, http.StatusNotFound, `{"code":"not_found","message":"not found"}`},
+		{"invalid payload", "/acme.calc.v1.CalcService/Find", `"x"`, nil, http.StatusBadRequest, ""},
+		{"unknown method", "/acme.calc.v1.CalcService/Missing", "{}", nil, http.StatusNotImplemented, ""},
+		{"wrong service", "/other.Service/Find", "1", nil, http.StatusNotImplemented, ""},
+		{"timeout", "/acme.calc.v1.CalcService/Sleep", "{}", []string{"Connect-Timeout-Ms", "10"}, http.StatusGatewayTimeout, `{"code":"deadline_exceeded","message":"context deadline exceeded"}`},
+		{"bad version", "/acme.calc.v1.CalcService/Find", "1", []string{"Connect-Protocol-Version", "2"}, http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := call(c.path, c.body, c.headers...)
+			if res.Code != c.status {
+				t.Fatal(res.Code, res.Body.String())
+			}
+			if c.output != "" && res.Body.String() != c.output {
+				t.Fatal(res.Body.String())
+			}
+			if h := res.Header().Get("Content-Type"); h != "application/json" {
+				t.Fatal(h)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/acme.calc.v1.CalcService/Find", bytes.NewBufferString("1"))
+	req.Header.Set("Content-Type", "application/proto")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	if res.Code != http.StatusUnsupportedMediaType {
+		t.Fatal(res.Code)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	var direct int
+	// the same as generated by rpc-gen -target jrpc
+	dispatcher := Static(map[string]Direct[*Calc]{
+		"Sum": func(receiver *Calc, request *http.Request, payload json.RawMessage) (any, error) {
+			direct++
+			var arg0 []int
+			if err := DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Sum(arg0), nil
+		},
+		"Find": func(receiver *Calc, request *http.Request, payload json.RawMessage) (any, error) {
+			direct++
+			var arg0 int
+			if err := DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Find(arg0)
+		},
+		"Noop": func(receiver *Calc, request *http.Request, payload json.RawMessage) (any, error) {
+			direct++
+			receiver.Noop()
+			return nil, nil
+		},
+	})
+
+	reflected := New(&Calc{})
+	static := New(&Calc{}, dispatcher)
+	cases := []struct {
+		method  string
+		payload string
+	}{
+		{"Sum", "[1,2,3]"},
+		{"Sum", `"x"`},
+		{"Find", "1"},
+		{"Find", "2"},
+		{"Noop", ""},
+		{"Hi", ""},
+	}
+	for _, c := range cases {
+		expected := httptest.NewRecorder()
+		reflected.ServeHTTP(expected, httptest.NewRequest(http.MethodPost, "/"+c.method, bytes.NewBufferString(c.payload)))
+		actual := httptest.NewRecorder()
+		static.ServeHTTP(actual, httptest.NewRequest(http.MethodPost, "/"+c.method, bytes.NewBufferString(c.payload)))
+		if expected.Code != actual.Code || expected.Body.String() != actual.Body.String() {
+			t.Errorf("%s %s: expected %d %q, got %d %q", c.method, c.payload, expected.Code, expected.Body.String(), actual.Code, actual.Body.String())
+		}
+	}
+	if direct != 5 {
+		t.Errorf("expected 5 direct calls, got %d", direct)
+	}
+}
+
+type valueCalc struct{}
+
+func (v valueCalc) Double(value int) int {
+	return value * 2
+}
+
+func TestStatic_value(t *testing.T) {
+	var direct int
+	// generated by rpc-gen -target jrpc for *valueCalc
+	dispatcher := Static(map[string]Direct[*valueCalc]{
+		"Double": func(receiver *valueCalc, request *http.Request, payload json.RawMessage) (any, error) {
+			direct++
+			var arg0 int
+			if err := DecodePayload(payload, &arg0); err != nil {
+				return nil, err
+			}
+			return receiver.Double(arg0), nil
+		},
+	})
+
+	res := httptest.NewRecorder()
+	New(valueCalc{}, dispatcher).ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/Double", bytes.NewBufferString("21")))
+	if res.Code != http.StatusOK || res.Body.String() != "42" {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	if direct != 1 {
+		t.Errorf("object indexed by value should be dispatched directly, got %d direct calls", direct)
+	}
+}
@@ -0,0 +1,61 @@
+package jrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/internal/framing"
+	"github.com/reddec/rpc/internal/session"
+)
+
+// Serve exposes methods over byte streams (for example, stdin and stdout of subprocess) using the same message
+// protocol as [rpc.Serve], except args is payload (any JSON value) instead of array.
+//
+//	-> {"id": 1, "method": "Sum", "args": [1, 2]}
+//	<- {"id": 1, "result": 3}
+//
+// Calls are executed concurrently and responses are matched by id. Request-scoped parameters are resolved
+// from synthetic POST request (without headers) with per-call context derived from ctx.
+// Serve returns nil at the end of input once all in-flight calls are finished.
+func (srv *RPC) Serve(ctx context.Context, input io.Reader, output io.Writer, mode rpc.Framing) error {
+	base, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+	if err != nil {
+		return err
+	}
+	reader := framing.NewReader(input, mode == rpc.ContentLength)
+	writer := framing.NewWriter(output, mode == rpc.ContentLength)
+	return session.Serve(ctx, reader.Read, writer.Write, func(ctx context.Context, msg session.Request, call *session.Call) {
+		m, ok := srv.methods[msg.Method]
+		if !ok {
+			_ = call.Fail(http.StatusNotFound, errors.New("unknown method "+msg.Method))
+			return
+		}
+		if len(m.fileTypes) > 0 || m.binary {
+			_ = call.Fail(http.StatusBadRequest, errors.New("files and binary results are supported only over HTTP"))
+			return
+		}
+		input := msg.Args
+		if m.hasArg && len(input) == 0 {
+			input = json.RawMessage("null")
+		}
+		request := base.WithContext(rpc.WithResponse(ctx, rpc.NewResponse()))
+		result, err := m.call(request, input, nil)
+		if err != nil {
+			_ = call.Fail(http.StatusInternalServerError, err)
+			return
+		}
+		var output json.RawMessage
+		if m.hasResponse {
+			output, err = json.Marshal(result)
+			if err != nil {
+				_ = call.Fail(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		_ = call.Result(output)
+	}, 0)
+}
@@ -0,0 +1,71 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// Direct is statically typed call of method of T, usually generated by cmd/rpc-gen. It decodes payload
+// by [DecodePayload] (payload is nil for methods without payload), takes request-scoped parameters
+// (context.Context, *http.Request, http.Header) from request and calls method without reflection.
+type Direct[T any] func(receiver T, request *http.Request, payload json.RawMessage) (any, error)
+
+// Static registers direct calls (by Go method name), which replace reflection-based calls with identical
+// wire behaviour. Calls are used only if indexed object has type T. Methods without direct call, methods with files,
+// and methods with custom request-scoped parameters (see [Inject]) are called by reflection.
+//
+// Calls for pointer type *E (generated by rpc-gen) also serve object of type E (for example jrpc.New(server)),
+// unless calls for E are registered: methods with value receiver are called on a copy of receiver.
+//
+//	api := jrpc.New(&server, ServerDispatcher()) // ServerDispatcher is generated by rpc-gen -target jrpc
+func Static[T any](calls map[string]Direct[T]) Option {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return func(cfg *config) {
+		var methods = make(map[string]direct, len(calls))
+		var values = make(map[string]direct, len(calls))
+		for name, call := range calls {
+			call := call
+			methods[name] = func(receiver reflect.Value, request *http.Request, payload json.RawMessage) (any, error) {
+				return call(receiver.Interface().(T), request, payload)
+			}
+			values[name] = func(receiver reflect.Value, request *http.Request, payload json.RawMessage) (any, error) {
+				return call(addressOf(receiver).Interface().(T), request, payload)
+			}
+		}
+		cfg.static[t] = methods
+		if t.Kind() != reflect.Ptr {
+			return
+		}
+		if _, ok := cfg.static[t.Elem()]; !ok {
+			cfg.static[t.Elem()] = values
+		}
+	}
+}
+
+// addressOf returns pointer to copy of value.
+func addressOf(value reflect.Value) reflect.Value {
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	return ptr
+}
+
+// DecodePayload decodes payload into value. Decoding error is reported the same way as for reflection-based calls.
+func DecodePayload(payload json.RawMessage, value any) error {
+	if err := json.Unmarshal(payload, value); err != nil {
+		return &payloadError{err: fmt.Errorf("decode payload: %w", err)}
+	}
+	return nil
+}
+
+type direct func(receiver reflect.Value, request *http.Request, payload json.RawMessage) (any, error)
+
+// directCall finds direct call of method. Calls are used only if all request-scoped parameters
+// are resolved by default providers and method has no files.
+func directCall(cfg *config, t reflect.Type, method reflect.Method, fileIndexes []int, custom bool) direct {
+	if len(fileIndexes) > 0 || custom {
+		return nil
+	}
+	return cfg.static[t][method.Name]
+}
@@ -1,9 +1,11 @@
 package jrpc
 
 import (
-	"math"
-	"reflect"
 	"strconv"
+	"strings"
+
+	"github.com/reddec/rpc/internal/security"
+	"github.com/reddec/rpc/jsonschema"
 )
 
 // Schema represents OpenAPI definition of endpoints.
@@ -15,8 +17,10 @@ type openAPI struct {
 		Version string `json:"version" yaml:"version"`
 	} `json:"info" yaml:"info"`
 	Paths      map[string]endpointPath `json:"paths,omitempty" yaml:"paths,omitempty"`
+	Security   []map[string][]string   `json:"security,omitempty" yaml:"security,omitempty"`
 	Components struct {
-		Schemas map[string]*Type `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+		Schemas         map[string]*Type           `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
 	} `json:"components,omitempty" yaml:"components,omitempty"`
 }
 
@@ -30,9 +34,11 @@ type endpointPath struct {
 }
 
 type endpoint struct {
-	Summary     string  `json:"summary,omitempty" yaml:"summary,omitempty"`
-	OperationID string  `json:"operationId" yaml:"operationId"`
-	RequestBody payload `json:"requestBody" yaml:"requestBody"`
+	Summary     string                `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationID string                `json:"operationId" yaml:"operationId"`
+	Security    []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"`
+	RequestBody payload               `json:"requestBody" yaml:"requestBody"`
 	Responses   struct {
 		OK            payload  `json:"200" yaml:"200"`
 		BadRequest    *payload `json:"400" yaml:"400"`
@@ -41,226 +47,76 @@ type endpoint struct {
 }
 
 type contentType struct {
-	Schema *Type `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Schema   *Type                   `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Encoding map[string]partEncoding `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+type partEncoding struct {
+	ContentType string `json:"contentType,omitempty" yaml:"contentType,omitempty"`
 }
 
 type payload struct {
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 	Content     struct {
-		JSON  *contentType `json:"application/json,omitempty" yaml:"application/json,omitempty"`
-		Plain *contentType `json:"text/plain,omitempty" yaml:"text/plain,omitempty"`
+		JSON      *contentType `json:"application/json,omitempty" yaml:"application/json,omitempty"`
+		Plain     *contentType `json:"text/plain,omitempty" yaml:"text/plain,omitempty"`
+		Multipart *contentType `json:"multipart/form-data,omitempty" yaml:"multipart/form-data,omitempty"`
+		Binary    *contentType `json:"application/octet-stream,omitempty" yaml:"application/octet-stream,omitempty"`
 	} `json:"content,omitempty" yaml:"content,omitempty"`
 }
 
-type Type struct {
-	Type        string           `json:"type,omitempty" yaml:"type,omitempty"`
-	Format      string           `json:"format,omitempty" yaml:"format,omitempty"`
-	Ref         string           `json:"$ref,omitempty" yaml:"$ref,omitempty"`
-	Items       *Type            `json:"items,omitempty" yaml:"items,omitempty"`
-	Properties  map[string]*Type `json:"properties,omitempty" yaml:"properties,omitempty"`
-	Required    []string         `json:"required,omitempty" yaml:"required,omitempty"`
-	Minimum     *int64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
-	Maximum     int64            `json:"maximum,omitempty" yaml:"maximum,omitempty"`
-	PrefixItems []*Type          `json:"prefixItems,omitempty" yaml:"prefixItems,omitempty"`
-	MinItems    int              `json:"minItems,omitempty" yaml:"minItems,omitempty"`
-	MaxItems    int              `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
-	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
-	Name        string           `json:"-" yaml:"-"`
-}
-
-// Option configures schema creation.
-type Option func(builder *schemaBuilder)
-
-func generateOpenAPI(index map[string]*exposedMethod, options ...Option) *openAPI {
-	var zero = new(int64)
-	sb := schemaBuilder{
-		components: make(map[schemaRef]*Type),
-		names:      make(map[string]int),
-		hooks: map[schemaRef]*Type{
-			{pkg: "time", name: "Time"}:                             {Type: "string", Format: "date-time"},
-			{pkg: "time", name: "Duration"}:                         {Type: "string", Description: "duration with unit prefix"},
-			{pkg: "github.com/shopspring/decimal", name: "Decimal"}: {Type: "string", Description: "precise representation of decimal value"},
-		},
-		defaults: schemaDefaults{
-			// defaults avoids creating same type,
-			// reduces memory allocation, and allows customization
-			Int:   &Type{Type: "integer"},
-			Int64: &Type{Type: "integer", Format: "int64"},
-			Int32: &Type{Type: "integer", Format: "int32"},
-			Int16: &Type{Type: "integer", Maximum: math.MaxInt16},
-			Int8:  &Type{Type: "integer", Maximum: math.MaxInt8},
-
-			UInt:   &Type{Type: "integer", Minimum: zero},
-			UInt64: &Type{Type: "integer", Format: "int64", Minimum: zero},
-			UInt32: &Type{Type: "integer", Format: "int32", Minimum: zero},
-			UInt16: &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint16},
-			UInt8:  &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint8},
-
-			String:  &Type{Type: "string"},
-			Bool:    &Type{Type: "boolean"},
-			Float32: &Type{Type: "number", Format: "float"},
-			Float64: &Type{Type: "number", Format: "double"},
-
-			Base64: &Type{Type: "string", Format: "byte"},
-			Any:    &Type{},
-		},
-	}
+// Type is JSON Schema of value, see [jsonschema.Type].
+type Type = jsonschema.Type
 
-	for _, opt := range options {
-		opt(&sb)
-	}
-	schema := sb.build(index)
-	return schema
-}
+// Discriminator of polymorphic type (see package poly).
+type Discriminator = jsonschema.Discriminator
 
-type schemaRef struct {
-	pkg  string
-	name string
-}
-
-func refOf(t reflect.Type) schemaRef {
-	return schemaRef{
-		pkg:  t.PkgPath(),
-		name: t.Name(),
-	}
-}
-
-type schemaDefaults struct {
-	Int   *Type
-	Int64 *Type
-	Int32 *Type
-	Int16 *Type
-	Int8  *Type
-
-	UInt   *Type
-	UInt64 *Type
-	UInt32 *Type
-	UInt16 *Type
-	UInt8  *Type
-
-	String  *Type
-	Bool    *Type
-	Float32 *Type
-	Float64 *Type
-
-	Base64 *Type
-	Any    *Type
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{docs: make(map[string]string)}
 }
 
 type schemaBuilder struct {
-	title      string
-	version    string
-	components map[schemaRef]*Type
-	names      map[string]int
-	hooks      map[schemaRef]*Type
-	defaults   schemaDefaults
-	urls       []string
+	title    string
+	version  string
+	urls     []string
+	docs     map[string]string // descriptions of methods, see Docs
+	options  []jsonschema.Option
+	schemes  map[string]*SecurityScheme
+	security security.Requirements
 }
 
-func (sb *schemaBuilder) walk(t reflect.Type) *Type {
-	if mock, exists := sb.hooks[refOf(t)]; exists {
-		return mock
-	}
-	switch t.Kind() {
-	case reflect.Ptr:
-		return sb.walk(t.Elem())
-	case reflect.Int:
-		return sb.defaults.Int
-	case reflect.Int64:
-		return sb.defaults.Int64
-	case reflect.Int32:
-		return sb.defaults.Int32
-	case reflect.Int16:
-		return sb.defaults.Int16
-	case reflect.Int8:
-		return sb.defaults.Int8
-
-	case reflect.Uint:
-		return sb.defaults.UInt
-	case reflect.Uint64:
-		return sb.defaults.UInt64
-	case reflect.Uint32:
-		return sb.defaults.UInt32
-	case reflect.Uint16:
-		return sb.defaults.UInt16
-	case reflect.Uint8:
-		return sb.defaults.UInt8
-	case reflect.String:
-		return sb.defaults.String
-	case reflect.Bool:
-		return sb.defaults.Bool
-	case reflect.Float32:
-		return sb.defaults.Float32
-	case reflect.Float64:
-		return sb.defaults.Float64
-	case reflect.Slice:
-		if t.Elem().Kind() == reflect.Uint8 {
-			// base64
-			return sb.defaults.Base64
-		}
-		return &Type{Type: "array", Items: sb.walk(t.Elem())}
-	case reflect.Array:
-		return &Type{Type: "array", Items: sb.walk(t.Elem()), MinItems: t.Len(), MaxItems: t.Len()}
-	case reflect.Struct:
-		if t.Name() == "" { //anonymous, we need to embed
-			return sb.walkStruct(t)
-		}
-		info := sb.walkStruct(t)
-		return &Type{Ref: "#/components/schemas/" + info.Name}
-	default:
-		return sb.defaults.Any
+// summarize splits description to summary (first line) and full description (if it has more than one line).
+func summarize(description string) (summary string, full string) {
+	summary, _, multiline := strings.Cut(description, "\n")
+	if multiline {
+		full = description
 	}
+	return summary, full
 }
 
-func (sb *schemaBuilder) walkStruct(t reflect.Type) *Type {
-	ref := refOf(t)
-	var anonymous = ref.name == ""
-
-	if !anonymous {
-		if parsed, ok := sb.components[ref]; ok {
-			return parsed
-		}
-	}
-
-	cardinality := sb.names[t.Name()]
-	sb.names[t.Name()] += 1
-
-	n := t.NumField()
+// walkMultipart describes multipart request: part "args" with JSON payload and binary parts for files.
+func walkMultipart(types *jsonschema.Builder, method *exposedMethod) *contentType {
 	var res = &Type{
 		Type:       "object",
-		Properties: make(map[string]*Type, n),
-	}
-	if cardinality == 0 {
-		res.Name = t.Name()
-	} else {
-		res.Name = t.Name() + strconv.Itoa(n)
+		Properties: map[string]*Type{},
 	}
-
-	if !anonymous {
-		// enable self reference
-		sb.components[ref] = res
+	var content = &contentType{Schema: res}
+	if method.hasArg {
+		res.Properties["args"] = types.Schema(method.argType)
+		content.Encoding = map[string]partEncoding{"args": {ContentType: "application/json"}}
 	}
-
-	for i := 0; i < n; i++ {
-		f := t.Field(i)
-		if !f.IsExported() {
-			continue
-		}
-		value := f.Tag.Get("json")
-		if value == "-" {
-			continue
-		}
-		if value == "" {
-			value = f.Name
-		}
-		res.Properties[value] = sb.walk(f.Type)
+	for i := range method.fileTypes {
+		name := "file" + strconv.Itoa(i)
+		res.Properties[name] = types.Defaults.Binary
+		res.Required = append(res.Required, name)
 	}
-	return res
+	return content
 }
 
 func (sb *schemaBuilder) build(index map[string]*exposedMethod) *openAPI {
+	types := jsonschema.New("#/components/schemas/", sb.options...)
 	var schema = openAPI{
-		OpenAPI: "3.0.0",
+		OpenAPI: "3.1.0",
 		Paths:   map[string]endpointPath{},
 	}
 	schema.Info.Title = sb.title
@@ -270,7 +126,7 @@ func (sb *schemaBuilder) build(index map[string]*exposedMethod) *openAPI {
 	}
 
 	// we are preparing all response types since they all the same for all endpoints.
-	var errorType = &contentType{Schema: sb.defaults.String}
+	var errorType = &contentType{Schema: types.Defaults.String}
 
 	var badRequest = &payload{
 		Description: "Payload can not be unmarshalled to arguments or number of arguments not enough, returns error message (plain text)",
@@ -285,59 +141,141 @@ func (sb *schemaBuilder) build(index map[string]*exposedMethod) *openAPI {
 	for method, info := range index {
 		var path endpointPath
 		path.Post.OperationID = method
-		if info.hasArg {
+		path.Post.Security = sb.security.Method(method)
+		path.Post.Summary, path.Post.Description = summarize(info.description)
+		if len(info.fileTypes) > 0 {
+			path.Post.RequestBody.Content.Multipart = walkMultipart(types, info)
+		} else if info.hasArg {
 			path.Post.RequestBody.Content.JSON = new(contentType)
-			path.Post.RequestBody.Content.JSON.Schema = sb.walk(info.argType)
+			path.Post.RequestBody.Content.JSON.Schema = types.Schema(info.argType)
 		}
 		path.Post.Responses.OK.Description = "Success"
 
-		path.Post.Responses.OK.Content.JSON = new(contentType)
-		if info.hasResponse {
-			path.Post.Responses.OK.Content.JSON.Schema = sb.walk(info.retType)
+		if info.binary {
+			path.Post.Responses.OK.Content.Binary = &contentType{Schema: types.Defaults.Binary}
+		} else {
+			path.Post.Responses.OK.Content.JSON = new(contentType)
+			if info.hasResponse {
+				path.Post.Responses.OK.Content.JSON.Schema = types.Schema(info.retType)
+			}
 		}
 
 		path.Post.Responses.BadRequest = badRequest
 		path.Post.Responses.InternalError = internalError
-		schema.Paths["/"+method] = path
+		schema.Paths["/"+info.name] = path
 	}
 
-	schema.Components.Schemas = map[string]*Type{}
-	for ref, component := range sb.components {
-		if ref.name == "" {
-			continue
-		}
-		schema.Components.Schemas[component.Name] = component
-	}
+	schema.Components.Schemas = types.Components()
+	schema.Components.SecuritySchemes = sb.schemes
+	schema.Security = sb.security.Global()
 	return &schema
 }
 
 // Title for schema.
 func Title(title string) Option {
-	return func(builder *schemaBuilder) {
-		builder.title = title
+	return func(cfg *config) {
+		cfg.schema.title = title
 	}
 }
 
 // Version of API.
 func Version(version string) Option {
-	return func(builder *schemaBuilder) {
-		builder.version = version
+	return func(cfg *config) {
+		cfg.schema.version = version
 	}
 }
 
 // Define specific type as OpenAPI definition.
 func Define(pkg, name string, definition *Type) Option {
-	return func(builder *schemaBuilder) {
-		builder.hooks[schemaRef{
-			pkg:  pkg,
-			name: name,
-		}] = definition
-	}
+	return Types(jsonschema.Define(pkg, name, definition))
 }
 
 // URL for OpenAPI server.
 func URL(urls ...string) Option {
-	return func(builder *schemaBuilder) {
-		builder.urls = urls
+	return func(cfg *config) {
+		cfg.schema.urls = urls
+	}
+}
+
+// AllOf describes named embedded structs by allOf composition (reference to embedded type and own properties)
+// instead of flattened properties. Embedded structs with conflicting properties are always flattened.
+func AllOf() Option {
+	return Types(jsonschema.AllOf())
+}
+
+// Docs sets descriptions of methods (by Go name or by package path, receiver type and Go name,
+// ex: github.com/foo/bar.Server.Create), types (by package path and name, ex: github.com/foo/bar.User)
+// and fields (ex: github.com/foo/bar.User.Name) for schema and tools. Usually table is generated from doc comments
+// by rpc-gen with -docs flag. [Describe] and field tag `description` have priority over docs.
+//
+//	jrpc.New(&server, jrpc.Docs(ServerDocs()))
+func Docs(table map[string]string) Option {
+	return func(cfg *config) {
+		for key, description := range table {
+			cfg.schema.docs[key] = description
+		}
+		Types(jsonschema.Docs(table))(cfg)
+	}
+}
+
+// SecurityScheme describes authentication (type: http or apiKey), see [Security].
+// The same type is used by package schema.
+type SecurityScheme = security.Scheme
+
+// BearerAuth is token in Authorization header. Format (optional) is a hint for clients, ex: JWT.
+func BearerAuth(format string) *SecurityScheme {
+	return security.Bearer(format)
+}
+
+// BasicAuth is user and password in Authorization header.
+func BasicAuth() *SecurityScheme {
+	return security.Basic()
+}
+
+// HeaderAuth is API key in header.
+func HeaderAuth(header string) *SecurityScheme {
+	return security.Header(header)
+}
+
+// CookieAuth is API key in cookie.
+func CookieAuth(cookie string) *SecurityScheme {
+	return security.Cookie(cookie)
+}
+
+// Security declares authentication scheme by name. Scheme is required by listed methods (by Go name),
+// or by all methods if list is empty. Several schemes of method (or global) are alternatives.
+//
+//	jrpc.New(&server, jrpc.Security("token", jrpc.BearerAuth("JWT")), jrpc.Public("Login"))
+func Security(name string, scheme *SecurityScheme, methods ...string) Option {
+	return func(cfg *config) {
+		if cfg.schema.schemes == nil {
+			cfg.schema.schemes = make(map[string]*SecurityScheme)
+		}
+		cfg.schema.schemes[name] = scheme
+		cfg.schema.security.Require(name, methods...)
+	}
+}
+
+// Public marks methods (by Go name) as available without authentication, regardless of global schemes.
+func Public(methods ...string) Option {
+	return func(cfg *config) {
+		cfg.schema.security.Public(methods...)
 	}
 }
+
+// Types applies options of schema engine (for schema and tools), see package jsonschema.
+func Types(options ...jsonschema.Option) Option {
+	return func(cfg *config) {
+		cfg.schema.options = append(cfg.schema.options, options...)
+	}
+}
+
+// Definer is implemented by types (or pointers to types) with custom schema, see [jsonschema.Definer].
+//
+//	func (Money) JSONSchema() *jrpc.Type {
+//		return &jrpc.Type{Type: "string", Description: "amount with currency, ex: 10.5 USD"}
+//	}
+type Definer = jsonschema.Definer
+
+// Enumer is implemented by types (or pointers to types) with fixed set of values, see [jsonschema.Enumer].
+type Enumer = jsonschema.Enumer
@@ -0,0 +1,130 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/jsonschema"
+)
+
+// ErrUnknownTool returned by [RPC.CallTool] if tool is not known.
+var ErrUnknownTool = errors.New("unknown tool")
+
+// payloadProperty is name of property which holds non-object payload in tool arguments.
+const payloadProperty = "payload"
+
+// Tool describes method for tool-calling integrations (for example, Model Context Protocol).
+// Arguments of tool are always JSON object: struct payload is used as-is, other payloads are wrapped
+// to property "payload", methods without payload accept empty object.
+type Tool struct {
+	Name        string          `json:"name"`                  // endpoint name
+	Description string          `json:"description,omitempty"` // see [Describe]
+	InputSchema json.RawMessage `json:"inputSchema"`           // self-contained JSON Schema of arguments
+	wrapped     bool
+}
+
+// Tools returns exposed methods as tools sorted by name. Methods with files or binary results are excluded.
+func (srv *RPC) Tools() []Tool {
+	return srv.tools
+}
+
+// CallTool calls method by tool name with arguments (JSON object) as described by [RPC.Tools].
+// Request is used for request-scoped parameters and context.
+func (srv *RPC) CallTool(request *http.Request, name string, arguments json.RawMessage) (json.RawMessage, error) {
+	idx := sort.Search(len(srv.tools), func(i int) bool {
+		return srv.tools[i].Name >= name
+	})
+	if idx == len(srv.tools) || srv.tools[idx].Name != name {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, name)
+	}
+	tool := srv.tools[idx]
+	m := srv.methods[name]
+
+	input := arguments
+	if len(input) == 0 {
+		input = json.RawMessage("null")
+	}
+	if tool.wrapped {
+		var args map[string]json.RawMessage
+		if err := json.Unmarshal(input, &args); err != nil {
+			return nil, fmt.Errorf("parse arguments: %w", err)
+		}
+		input = args[payloadProperty]
+		if input == nil {
+			input = json.RawMessage("null")
+		}
+	}
+
+	request = request.WithContext(rpc.WithResponse(request.Context(), rpc.NewResponse()))
+	result, err := m.call(request, input, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !m.hasResponse {
+		return nil, nil
+	}
+	return json.Marshal(result)
+}
+
+func (sb *schemaBuilder) tools(index map[string]*exposedMethod) []Tool {
+	types := jsonschema.New("#/$defs/", sb.options...)
+	var ans = make([]Tool, 0, len(index))
+	for _, info := range index {
+		if len(info.fileTypes) > 0 || info.binary {
+			continue
+		}
+		schema, wrapped := inputSchema(types, info)
+		ans = append(ans, Tool{
+			Name:        info.name,
+			Description: info.description,
+			InputSchema: schema,
+			wrapped:     wrapped,
+		})
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Name < ans[j].Name
+	})
+	return ans
+}
+
+// inputSchema builds self-contained JSON Schema (object) of method payload. Components are placed to $defs.
+func inputSchema(types *jsonschema.Builder, info *exposedMethod) (json.RawMessage, bool) {
+	var root *Type
+	var wrapped bool
+	argType := info.argType
+	for argType != nil && argType.Kind() == reflect.Ptr {
+		argType = argType.Elem()
+	}
+	switch {
+	case !info.hasArg:
+		root = &Type{Type: "object"}
+	case argType.Kind() == reflect.Struct && isObject(types.Definition(argType)):
+		root = types.Definition(argType)
+	default:
+		wrapped = true
+		root = &Type{
+			Type:       "object",
+			Properties: map[string]*Type{payloadProperty: types.Schema(info.argType)},
+			Required:   []string{payloadProperty},
+		}
+	}
+
+	data, err := json.Marshal(struct {
+		*Type
+		Defs map[string]*Type `json:"$defs,omitempty"`
+	}{Type: root, Defs: types.Defs(root)})
+	if err != nil {
+		panic(err) // should never happen
+	}
+	return data, wrapped
+}
+
+// isObject checks that schema describes struct (not custom definition).
+func isObject(schema *Type) bool {
+	return schema.Type == "object" || len(schema.AllOf) > 0
+}
@@ -1,7 +1,33 @@
-export default function RPC(baseURL = "") {
+function words(name) {
+    const upper = (c) => c >= "A" && c <= "Z", lower = (c) => c >= "a" && c <= "z", digit = (c) => c >= "0" && c <= "9";
+    const res = [];
+    let start = 0;
+    for (let i = 1; i <= name.length; i++) {
+        const prev = name[i - 1], cur = name[i];
+        if (i < name.length && !(cur === "_" || cur === "-" ||
+            upper(cur) && (lower(prev) || digit(prev)) ||
+            upper(prev) && upper(cur) && lower(name[i + 1] || ""))) continue
+        const word = name.slice(start, i).replace(/^[_-]+|[_-]+$/g, "");
+        if (word) res.push(word);
+        start = i;
+    }
+    return res
+}
+
+// same strategies as in github.com/reddec/rpc/naming
+const strategies = {
+    "lower": (name) => name.toLowerCase(),
+    "as-is": (name) => name,
+    "camel": (name) => words(name).map((w, i) => i === 0 ? w.toLowerCase() : w[0].toUpperCase() + w.slice(1)).join(""),
+    "snake": (name) => words(name).join("_").toLowerCase(),
+    "kebab": (name) => words(name).join("-").toLowerCase(),
+}
+
+export default function RPC(baseURL = "", naming = "lower") {
+    const convert = strategies[naming] || strategies.lower;
     return new Proxy({}, {
         get(obj, method) {
-            method = method.toLowerCase();
+            method = convert(method);
             if (method in obj) return obj[method]
             return obj[method] = async function () {
                 const res = await fetch(baseURL + "/" + encodeURIComponent(method), {
@@ -16,4 +42,44 @@ export default function RPC(baseURL = "") {
             }
         }
     })
-}
\ No newline at end of file
+}
+
+// WebSocket client: calls are multiplexed over single connection (url must be absolute: ws:// or wss://).
+// If the last argument is a function, it receives pushed items of stream. Use $close() to close connection.
+export function WebSocketRPC(url, naming = "lower") {
+    const convert = strategies[naming] || strategies.lower;
+    const calls = {};
+    let seq = 0;
+    const socket = new WebSocket(url);
+    const opened = new Promise((resolve, reject) => {
+        socket.onopen = resolve;
+        socket.onerror = reject;
+    });
+    socket.onmessage = (event) => {
+        const msg = JSON.parse(event.data), call = calls[msg.id];
+        if (!call) return;
+        if ("item" in msg) return call.onItem && call.onItem(msg.item);
+        delete calls[msg.id];
+        if (msg.error) call.reject(new Error(msg.error)); else call.resolve(msg.result);
+    };
+    socket.onclose = () => {
+        for (const id in calls) calls[id].reject(new Error("connection closed"));
+    };
+    return new Proxy({}, {
+        get(obj, method) {
+            if (method === "$close") return () => socket.close();
+            method = convert(method);
+            if (method in obj) return obj[method]
+            return obj[method] = async function () {
+                const args = Array.prototype.slice.call(arguments);
+                const onItem = typeof args[args.length - 1] === "function" ? args.pop() : undefined;
+                await opened;
+                const id = ++seq;
+                return new Promise((resolve, reject) => {
+                    calls[id] = {resolve, reject, onItem};
+                    socket.send(JSON.stringify({id, method, args}));
+                })
+            }
+        }
+    })
+}
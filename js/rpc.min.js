@@ -1 +1 @@
-function a(e=""){return new Proxy({},{get(r,n){return n=n.toLowerCase(),n in r?r[n]:r[n]=async function(){let t=await fetch(e+"/"+encodeURIComponent(n),{method:"POST",body:JSON.stringify(Array.prototype.slice.call(arguments)),headers:{"Content-Type":"application/json"}});if(!t.ok)throw new Error(await t.text());return await t.json()}}})}export{a as default};
+function p(e){let o=t=>t>="A"&&t<="Z",f=t=>t>="a"&&t<="z",l=t=>t>="0"&&t<="9",s=[],a=0;for(let t=1;t<=e.length;t++){let c=e[t-1],n=e[t];if(t<e.length&&!(n==="_"||n==="-"||o(n)&&(f(c)||l(c))||o(c)&&o(n)&&f(e[t+1]||"")))continue;let r=e.slice(a,t).replace(/^[_-]+|[_-]+$/g,"");r&&s.push(r),a=t}return s}var i={lower:e=>e.toLowerCase(),"as-is":e=>e,camel:e=>p(e).map((o,f)=>f===0?o.toLowerCase():o[0].toUpperCase()+o.slice(1)).join(""),snake:e=>p(e).join("_").toLowerCase(),kebab:e=>p(e).join("-").toLowerCase()};function u(e="",o="lower"){let f=i[o]||i.lower;return new Proxy({},{get(l,s){return s=f(s),s in l?l[s]:l[s]=async function(){let a=await fetch(e+"/"+encodeURIComponent(s),{method:"POST",body:JSON.stringify(Array.prototype.slice.call(arguments)),headers:{"Content-Type":"application/json"}});if(!a.ok)throw new Error(await a.text());return await a.json()}}})}function d(e,o="lower"){let f=i[o]||i.lower,l={},s=0,a=new WebSocket(e),t=new Promise((c,n)=>{a.onopen=c,a.onerror=n});return a.onmessage=c=>{let n=JSON.parse(c.data),r=l[n.id];if(r){if("item"in n)return r.onItem&&r.onItem(n.item);delete l[n.id],n.error?r.reject(new Error(n.error)):r.resolve(n.result)}},a.onclose=()=>{for(let c in l)l[c].reject(new Error("connection closed"))},new Proxy({},{get(c,n){return n==="$close"?()=>a.close():(n=f(n),n in c?c[n]:c[n]=async function(){let r=Array.prototype.slice.call(arguments),h=typeof r[r.length-1]=="function"?r.pop():void 0;await t;let g=++s;return new Promise((m,y)=>{l[g]={resolve:m,reject:y,onItem:h},a.send(JSON.stringify({id:g,method:n,args:r}))})})}})}export{u as default,d as WebSocketRPC};
\ No newline at end of file
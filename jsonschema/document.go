@@ -0,0 +1,76 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Draft is URI of JSON Schema dialect used by [Document].
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Document is self-contained JSON Schema of type: components are placed to $defs.
+type Document struct {
+	Schema string `json:"$schema" yaml:"$schema"`
+	*Type  `yaml:",inline"`
+	Defs   map[string]*Type `json:"$defs,omitempty" yaml:"$defs,omitempty"`
+}
+
+// For describes type by self-contained JSON Schema (draft 2020-12). Type is described the same way as in
+// OpenAPI schemas (the same default mapping, options and components), but components are placed to $defs
+// and root is described in-place.
+//
+//	doc := jsonschema.For(reflect.TypeOf(Config{}), jsonschema.Docs(ConfigDocs()))
+func For(t reflect.Type, options ...Option) *Document {
+	b := New("#/$defs/", options...)
+	root := b.Definition(t)
+	return &Document{Schema: Draft, Type: root, Defs: b.Defs(root)}
+}
+
+// Of is generic version of [For].
+func Of[T any](options ...Option) *Document {
+	return For(reflect.TypeOf((*T)(nil)).Elem(), options...)
+}
+
+// Handler serves pre-generated JSON Schemas of types by name (the last element of path, ex: /schemas/config)
+// and sorted list of names at paths ending by slash. Unknown names cause 404 Not Found.
+//
+//	http.Handle("/schemas/", jsonschema.Handler(map[string]reflect.Type{
+//		"config": reflect.TypeOf(Config{}),
+//		"event":  reflect.TypeOf(Event{}),
+//	}))
+func Handler(types map[string]reflect.Type, options ...Option) http.Handler {
+	var rendered = make(map[string][]byte, len(types))
+	var names = make([]string, 0, len(types))
+	for name, t := range types {
+		rendered[name] = render(For(t, options...))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	index := render(names)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if strings.HasSuffix(request.URL.Path, "/") {
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write(index)
+			return
+		}
+		data, ok := rendered[path.Base(request.URL.Path)]
+		if !ok {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/schema+json")
+		_, _ = writer.Write(data)
+	})
+}
+
+func render(value any) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err) // this is impossible situation unless something like OOM happen
+	}
+	return data
+}
@@ -0,0 +1,608 @@
+// Package jsonschema describes Go types by JSON Schema the same way as encoding/json encodes them.
+// It's the engine of OpenAPI generators (see packages schema and jrpc).
+//
+// Named structs, types with definitions ([Definer]), enums ([Enumer]) and polymorphic values (see package poly)
+// are described as components and referenced by $ref. Names of components are allocated after types are walked,
+// so they don't depend on order: type name is used as-is if it's unique, otherwise it's qualified by package name
+// (ex: billing.User and users.User).
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/reddec/rpc/internal/jsonfield"
+	"github.com/reddec/rpc/poly"
+)
+
+// Type is JSON Schema of value.
+type Type struct {
+	Type          string           `json:"type,omitempty" yaml:"type,omitempty"`
+	Format        string           `json:"format,omitempty" yaml:"format,omitempty"`
+	Ref           string           `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Items         *Type            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties    map[string]*Type `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Additional    *Type            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required      []string         `json:"required,omitempty" yaml:"required,omitempty"`
+	Minimum       *int64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum       int64            `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	PrefixItems   []*Type          `json:"prefixItems,omitempty" yaml:"prefixItems,omitempty"`
+	MinItems      int              `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems      int              `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	AllOf         []*Type          `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	OneOf         []*Type          `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	Discriminator *Discriminator   `json:"discriminator,omitempty" yaml:"discriminator,omitempty"`
+	Enum          []any            `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Description   string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Examples      []any            `json:"examples,omitempty" yaml:"examples,omitempty"`
+	Name          string           `json:"-" yaml:"-"` // name of component
+}
+
+// Discriminator of polymorphic type (see package poly).
+type Discriminator struct {
+	PropertyName string            `json:"propertyName" yaml:"propertyName"`
+	Mapping      map[string]string `json:"mapping,omitempty" yaml:"mapping,omitempty"`
+}
+
+// Definer is implemented by types (or pointers to types) with custom schema. Definition of named type is
+// used as component. It has priority over struct layout, but not over definitions registered by [Define].
+//
+//	func (Money) JSONSchema() *jsonschema.Type {
+//		return &jsonschema.Type{Type: "string", Description: "amount with currency, ex: 10.5 USD"}
+//	}
+//
+// Types implementing json.Marshaler or encoding.TextMarshaler without definition are described as any value
+// or string respectively.
+type Definer interface {
+	JSONSchema() *Type
+}
+
+// Enumer is implemented by types (or pointers to types) with fixed set of values, which are listed as enum.
+//
+//	type Status string
+//
+//	func (Status) Enum() []any {
+//		return []any{"active", "blocked"}
+//	}
+type Enumer interface {
+	Enum() []any
+}
+
+// Defaults are schemas of basic types. They are shared between all usages, so they should not be modified.
+type Defaults struct {
+	Int   *Type
+	Int64 *Type
+	Int32 *Type
+	Int16 *Type
+	Int8  *Type
+
+	UInt   *Type
+	UInt64 *Type
+	UInt32 *Type
+	UInt16 *Type
+	UInt8  *Type
+
+	String  *Type
+	Bool    *Type
+	Float32 *Type
+	Float64 *Type
+
+	Base64 *Type // []byte
+	Binary *Type // raw content (files, streams)
+	Any    *Type
+}
+
+// Option configures builder.
+type Option func(builder *Builder)
+
+// Define specific type (by package path and name) as definition, which is used as-is instead of reference.
+func Define(pkg, name string, definition *Type) Option {
+	return func(builder *Builder) {
+		builder.hooks[schemaRef{pkg: pkg, name: name}] = definition
+	}
+}
+
+// AllOf describes named embedded structs by allOf composition (reference to embedded type and own properties)
+// instead of flattened properties. Embedded structs with conflicting properties are always flattened.
+func AllOf() Option {
+	return func(builder *Builder) {
+		builder.allOf = true
+	}
+}
+
+// Docs sets descriptions of types (by package path and name, ex: github.com/foo/bar.User) and fields
+// (ex: github.com/foo/bar.User.Name). Field tag `description` has priority over docs. Other keys (ex: methods)
+// are available by [Builder.Doc]. Usually table is generated from doc comments by rpc-gen with -docs flag.
+func Docs(table map[string]string) Option {
+	return func(builder *Builder) {
+		for key, description := range table {
+			builder.docs[key] = description
+		}
+	}
+}
+
+// Builder describes types and collects components. It's not thread-safe.
+type Builder struct {
+	Defaults   Defaults
+	prefix     string
+	hooks      map[schemaRef]*Type
+	docs       map[string]string
+	allOf      bool
+	components map[schemaRef]*Type
+	targets    map[*Type]schemaRef // references to components
+	names      map[schemaRef]string
+	owners     map[string]schemaRef // allocated names
+	fixups     []func()             // called after names are allocated
+}
+
+// New builder of schemas. Prefix is prepended to names of components in references
+// (ex: #/components/schemas/ for OpenAPI or #/$defs/ for JSON Schema).
+func New(prefix string, options ...Option) *Builder {
+	var zero = new(int64)
+	b := &Builder{
+		prefix:     prefix,
+		docs:       make(map[string]string),
+		components: make(map[schemaRef]*Type),
+		targets:    make(map[*Type]schemaRef),
+		names:      make(map[schemaRef]string),
+		owners:     make(map[string]schemaRef),
+		hooks: map[schemaRef]*Type{
+			{pkg: "time", name: "Time"}:                             {Type: "string", Format: "date-time"},
+			{pkg: "time", name: "Duration"}:                         {Type: "integer", Format: "int64", Description: "duration in nanoseconds"},
+			{pkg: "encoding/json", name: "RawMessage"}:              {Description: "raw JSON value"},
+			{pkg: "encoding/json/jsontext", name: "Value"}:          {Description: "raw JSON value"}, // alias of RawMessage in newer Go
+			{pkg: "net", name: "IP"}:                                {Type: "string", Description: "IPv4 or IPv6 address"},
+			{pkg: "net/netip", name: "Addr"}:                        {Type: "string", Description: "IPv4 or IPv6 address"},
+			{pkg: "github.com/google/uuid", name: "UUID"}:           {Type: "string", Format: "uuid"},
+			{pkg: "github.com/gofrs/uuid", name: "UUID"}:            {Type: "string", Format: "uuid"},
+			{pkg: "github.com/shopspring/decimal", name: "Decimal"}: {Type: "string", Description: "precise representation of decimal value"},
+		},
+		Defaults: Defaults{
+			// defaults avoids creating same type,
+			// reduces memory allocation, and allows customization
+			Int:   &Type{Type: "integer"},
+			Int64: &Type{Type: "integer", Format: "int64"},
+			Int32: &Type{Type: "integer", Format: "int32"},
+			Int16: &Type{Type: "integer", Maximum: math.MaxInt16},
+			Int8:  &Type{Type: "integer", Maximum: math.MaxInt8},
+
+			UInt:   &Type{Type: "integer", Minimum: zero},
+			UInt64: &Type{Type: "integer", Format: "int64", Minimum: zero},
+			UInt32: &Type{Type: "integer", Format: "int32", Minimum: zero},
+			UInt16: &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint16},
+			UInt8:  &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint8},
+
+			String:  &Type{Type: "string"},
+			Bool:    &Type{Type: "boolean"},
+			Float32: &Type{Type: "number", Format: "float"},
+			Float64: &Type{Type: "number", Format: "double"},
+
+			Base64: &Type{Type: "string", Format: "byte"},
+			Binary: &Type{Type: "string", Format: "binary"},
+			Any:    &Type{},
+		},
+	}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+// Schema of type. Components are described by references.
+func (b *Builder) Schema(t reflect.Type) *Type {
+	return b.walk(t)
+}
+
+// Definition of type: the same as [Builder.Schema], but component is returned as-is instead of reference.
+func (b *Builder) Definition(t reflect.Type) *Type {
+	schema := b.walk(t)
+	if key, ok := b.targets[schema]; ok {
+		return b.components[key]
+	}
+	return schema
+}
+
+// Doc returns description by key from [Docs].
+func (b *Builder) Doc(key string) string {
+	return b.docs[key]
+}
+
+// Components returns all collected components by names.
+func (b *Builder) Components() map[string]*Type {
+	b.resolve()
+	var ans = make(map[string]*Type, len(b.components))
+	for key, component := range b.components {
+		ans[b.names[key]] = component
+	}
+	return ans
+}
+
+// Defs returns components (by names) referenced by schema, directly or indirectly.
+func (b *Builder) Defs(schema *Type) map[string]*Type {
+	b.resolve()
+	var defs = make(map[string]*Type)
+	b.collect(schema, defs)
+	return defs
+}
+
+func (b *Builder) collect(t *Type, defs map[string]*Type) {
+	if t == nil {
+		return
+	}
+	if key, ok := b.targets[t]; ok {
+		name := b.names[key]
+		if defs[name] == nil {
+			defs[name] = b.components[key]
+			b.collect(defs[name], defs)
+		}
+	}
+	b.collect(t.Items, defs)
+	b.collect(t.Additional, defs)
+	for _, list := range [][]*Type{t.PrefixItems, t.AllOf, t.OneOf} {
+		for _, item := range list {
+			b.collect(item, defs)
+		}
+	}
+	for _, property := range t.Properties {
+		b.collect(property, defs)
+	}
+}
+
+// resolve allocates names for new components and fills references.
+func (b *Builder) resolve() {
+	var pending []schemaRef
+	var count = make(map[string]int)
+	for key := range b.components {
+		if _, ok := b.names[key]; !ok {
+			pending = append(pending, key)
+			count[sanitize(key.name)]++
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].name != pending[j].name {
+			return pending[i].name < pending[j].name
+		}
+		return pending[i].pkg < pending[j].pkg
+	})
+	for _, key := range pending {
+		name := sanitize(key.name)
+		if _, taken := b.owners[name]; taken || count[name] > 1 {
+			name = qualify(key.pkg, name)
+		}
+		candidate := name
+		for n := 2; ; n++ {
+			if _, taken := b.owners[candidate]; !taken {
+				break
+			}
+			candidate = name + strconv.Itoa(n)
+		}
+		b.owners[candidate] = key
+		b.names[key] = candidate
+		b.components[key].Name = candidate
+	}
+	for ref, key := range b.targets {
+		ref.Ref = b.prefix + b.names[key]
+	}
+	for _, fix := range b.fixups {
+		fix()
+	}
+}
+
+var (
+	packagePath = regexp.MustCompile(`[^\[\],\s]*/`)
+	invalidName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// qualify name by package name.
+func qualify(pkg string, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return sanitize(path.Base(pkg)) + "." + name
+}
+
+// sanitize name of type: type arguments of generic types are shortened, invalid characters are replaced.
+func sanitize(name string) string {
+	name = packagePath.ReplaceAllString(name, "")
+	name = invalidName.ReplaceAllString(name, "_")
+	for len(name) > 1 && name[len(name)-1] == '_' {
+		name = name[:len(name)-1]
+	}
+	return name
+}
+
+type schemaRef struct {
+	pkg  string
+	name string
+}
+
+func refOf(t reflect.Type) schemaRef {
+	return schemaRef{
+		pkg:  t.PkgPath(),
+		name: t.Name(),
+	}
+}
+
+// reference to component.
+func (b *Builder) reference(key schemaRef) *Type {
+	ref := &Type{}
+	if name, ok := b.names[key]; ok {
+		ref.Ref = b.prefix + name
+	}
+	b.targets[ref] = key
+	return ref
+}
+
+func (b *Builder) walk(t reflect.Type) *Type {
+	if mock, exists := b.hooks[refOf(t)]; exists {
+		return mock
+	}
+	if t.Kind() == reflect.Ptr {
+		return b.walk(t.Elem())
+	}
+	if union, ok := poly.Lookup(t); ok && t.Kind() == reflect.Struct {
+		// only poly.Value is encoded with discriminator, plain interfaces are encoded by encoding/json as-is
+		return b.walkUnion(union)
+	}
+	if definer, ok := reflect.New(t).Interface().(Definer); ok {
+		return b.walkDefined(t, definer.JSONSchema())
+	}
+	if enum, ok := reflect.New(t).Interface().(Enumer); ok {
+		return b.walkEnum(t, enum.Enum())
+	}
+	// custom encoding without definition
+	switch ptr := reflect.PtrTo(t); {
+	case ptr.Implements(jsonMarshaler):
+		return b.Defaults.Any
+	case ptr.Implements(textMarshaler):
+		return b.Defaults.String
+	}
+	return b.walkKind(t)
+}
+
+// walkKind describes type by its kind.
+func (b *Builder) walkKind(t reflect.Type) *Type {
+	switch t.Kind() {
+	case reflect.Int:
+		return b.Defaults.Int
+	case reflect.Int64:
+		return b.Defaults.Int64
+	case reflect.Int32:
+		return b.Defaults.Int32
+	case reflect.Int16:
+		return b.Defaults.Int16
+	case reflect.Int8:
+		return b.Defaults.Int8
+
+	case reflect.Uint:
+		return b.Defaults.UInt
+	case reflect.Uint64:
+		return b.Defaults.UInt64
+	case reflect.Uint32:
+		return b.Defaults.UInt32
+	case reflect.Uint16:
+		return b.Defaults.UInt16
+	case reflect.Uint8:
+		return b.Defaults.UInt8
+	case reflect.String:
+		return b.Defaults.String
+	case reflect.Bool:
+		return b.Defaults.Bool
+	case reflect.Float32:
+		return b.Defaults.Float32
+	case reflect.Float64:
+		return b.Defaults.Float64
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64
+			return b.Defaults.Base64
+		}
+		return &Type{Type: "array", Items: b.walk(t.Elem())}
+	case reflect.Array:
+		return &Type{Type: "array", Items: b.walk(t.Elem()), MinItems: t.Len(), MaxItems: t.Len()}
+	case reflect.Map:
+		if !isMapKey(t.Key()) {
+			return b.Defaults.Any // not supported by encoding/json
+		}
+		return &Type{Type: "object", Additional: b.walk(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" { //anonymous, we need to embed
+			return b.walkStruct(t, &Type{})
+		}
+		key := refOf(t)
+		if _, ok := b.components[key]; !ok {
+			component := &Type{Description: b.typeDoc(t)}
+			b.components[key] = component // registered before fields to enable self reference
+			b.walkStruct(t, component)
+		}
+		return b.reference(key)
+	default:
+		return b.Defaults.Any
+	}
+}
+
+// walkEnum describes type by its kind (or as string for text marshalers) with enumerated values.
+func (b *Builder) walkEnum(t reflect.Type, values []any) *Type {
+	var base = b.Defaults.String
+	if !reflect.PtrTo(t).Implements(textMarshaler) {
+		base = b.walkKind(t)
+	}
+	definition := *base
+	definition.Enum = values
+	return b.walkDefined(t, &definition)
+}
+
+// walkUnion describes registered interface as component with oneOf variants and discriminator. Each variant
+// is component which composes implementation and discriminator property.
+func (b *Builder) walkUnion(union *poly.Union) *Type {
+	key := refOf(union.Interface)
+	if _, ok := b.components[key]; ok {
+		return b.reference(key)
+	}
+	result := b.walkDefined(union.Interface, &Type{}) // registered before variants to enable self reference
+	component := b.components[key]
+	names := union.Names()
+	for _, name := range names {
+		variant := schemaRef{pkg: key.pkg, name: key.name + "_" + name}
+		b.components[variant] = &Type{
+			AllOf: []*Type{b.walk(union.Variants[name]), {
+				Type:       "object",
+				Properties: map[string]*Type{union.Property: {Type: "string", Enum: []any{name}}},
+				Required:   []string{union.Property},
+			}},
+		}
+		component.OneOf = append(component.OneOf, b.reference(variant))
+	}
+	component.Discriminator = &Discriminator{PropertyName: union.Property, Mapping: make(map[string]string, len(names))}
+	b.fixups = append(b.fixups, func() {
+		for i, name := range names {
+			component.Discriminator.Mapping[name] = component.OneOf[i].Ref
+		}
+	})
+	return result
+}
+
+// walkDefined registers definition of named type as component.
+func (b *Builder) walkDefined(t reflect.Type, definition *Type) *Type {
+	key := refOf(t)
+	if key.name == "" {
+		return definition
+	}
+	if _, ok := b.components[key]; ok {
+		return b.reference(key)
+	}
+	component := *definition
+	if component.Description == "" {
+		component.Description = b.typeDoc(t)
+	}
+	b.components[key] = &component
+	return b.reference(key)
+}
+
+// walkStruct fills properties of struct.
+func (b *Builder) walkStruct(t reflect.Type, res *Type) *Type {
+	var parents []*Type
+	fields := jsonfield.Fields(t)
+	if b.allOf {
+		parents, fields = b.walkEmbedded(t, fields)
+	}
+
+	var own = &Type{
+		Type:       "object",
+		Properties: make(map[string]*Type, len(fields)),
+	}
+	for _, f := range fields {
+		if f.Tag.String {
+			own.Properties[f.Name] = b.describeField(f, b.Defaults.String)
+		} else {
+			own.Properties[f.Name] = b.describeField(f, b.walk(f.Type))
+		}
+		if !f.Tag.OmitEmpty {
+			own.Required = append(own.Required, f.Name)
+		}
+	}
+	if len(parents) == 0 {
+		res.Type, res.Properties, res.Required = own.Type, own.Properties, own.Required
+	} else {
+		res.AllOf = append(parents, own)
+	}
+	return res
+}
+
+// describeField adds description (from tag or docs) and example (from tag) of field to property.
+func (b *Builder) describeField(f jsonfield.Field, property *Type) *Type {
+	description, ok := f.Source.Tag.Lookup("description")
+	if !ok && f.Owner.Name() != "" {
+		description = b.docs[f.Owner.PkgPath()+"."+f.Owner.Name()+"."+f.Source.Name]
+	}
+	example, hasExample := jsonfield.Example(f.Source)
+	if description == "" && !hasExample {
+		return property
+	}
+	described := *property
+	if key, ok := b.targets[property]; ok {
+		b.targets[&described] = key // copy of reference
+	}
+	if description != "" {
+		described.Description = description
+	}
+	if hasExample {
+		described.Examples = []any{example}
+	}
+	return &described
+}
+
+// typeDoc is description of named type from docs.
+func (b *Builder) typeDoc(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	return b.docs[t.PkgPath()+"."+t.Name()]
+}
+
+// walkEmbedded finds named embedded structs which can be composed by allOf and returns fields which are left.
+// Embedded struct is composed only if all its properties are promoted without conflicts, otherwise its properties
+// are flattened.
+func (b *Builder) walkEmbedded(t reflect.Type, fields []jsonfield.Field) ([]*Type, []jsonfield.Field) {
+	var promoted = make(map[string]bool, len(fields))
+	for _, f := range fields {
+		promoted[fmt.Sprint(f.Index)] = true
+	}
+
+	var parents []*Type
+	var composed = make(map[int]bool)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		et := sf.Type
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if !sf.Anonymous || et.Kind() != reflect.Struct || et.Name() == "" {
+			continue
+		}
+		if tag := jsonfield.Parse(sf); tag.Skip || tag.Name != "" {
+			continue
+		}
+		inherited := jsonfield.Fields(et)
+		var ok = len(inherited) > 0
+		for _, f := range inherited {
+			ok = ok && promoted[fmt.Sprint(append([]int{i}, f.Index...))]
+		}
+		if ok {
+			composed[i] = true
+			parents = append(parents, b.walk(et))
+		}
+	}
+
+	var own = make([]jsonfield.Field, 0, len(fields))
+	for _, f := range fields {
+		if !composed[f.Index[0]] {
+			own = append(own, f)
+		}
+	}
+	return parents, own
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isMapKey checks that map with such keys can be encoded as JSON object: keys are strings, integers or
+// implement encoding.TextMarshaler.
+func isMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshaler)
+}
@@ -0,0 +1,147 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/reddec/rpc/jsonschema"
+)
+
+type Cookie struct {
+	Flavor string
+}
+
+type Page[T any] struct {
+	Items []T
+	Next  *Page[T] `json:",omitempty"`
+}
+
+type Order struct {
+	Local  Cookie
+	Remote http.Cookie
+	Pages  Page[Cookie]
+	Tags   map[string]int
+}
+
+func TestBuilder_names(t *testing.T) {
+	forward := jsonschema.New("#/components/schemas/")
+	forward.Schema(reflect.TypeOf(Order{}))
+	reverse := jsonschema.New("#/components/schemas/")
+	reverse.Schema(reflect.TypeOf(Page[Cookie]{}))
+	reverse.Schema(reflect.TypeOf(http.Cookie{}))
+	reverse.Schema(reflect.TypeOf(Order{}))
+
+	for _, b := range []*jsonschema.Builder{forward, reverse} {
+		components := b.Components()
+		if len(components) != 4 {
+			t.Fatal(keys(components))
+		}
+		order := components["Order"]
+		if order == nil || order.Properties["Local"].Ref != "#/components/schemas/jsonschema_test.Cookie" {
+			t.Fatal(keys(components))
+		}
+		if order.Properties["Remote"].Ref != "#/components/schemas/http.Cookie" || components["http.Cookie"] == nil {
+			t.Error("package-qualified name expected")
+		}
+		page := components["Page_jsonschema_test.Cookie"]
+		if order.Properties["Pages"].Ref != "#/components/schemas/Page_jsonschema_test.Cookie" || page == nil {
+			t.Fatal(keys(components))
+		}
+		if page.Properties["Next"].Ref != order.Properties["Pages"].Ref {
+			t.Error("self reference expected")
+		}
+	}
+
+	first, _ := json.Marshal(forward.Components())
+	second, _ := json.Marshal(reverse.Components())
+	if string(first) != string(second) {
+		t.Errorf("output depends on order:\n%s\n%s", first, second)
+	}
+
+	// names are kept, new colliding types are qualified
+	forward.Schema(reflect.TypeOf(struct{ Order Order }{}))
+	if _, ok := forward.Components()["Order"]; !ok {
+		t.Error("name should be kept")
+	}
+}
+
+func TestBuilder_Defs(t *testing.T) {
+	b := jsonschema.New("#/$defs/", jsonschema.Define("time", "Time", &jsonschema.Type{Type: "string"}))
+	root := b.Definition(reflect.TypeOf(Order{}))
+	if root.Type != "object" || root.Ref != "" {
+		t.Fatal(root)
+	}
+	defs := b.Defs(root)
+	if len(defs) != 3 || defs["http.Cookie"] == nil || defs["Order"] != nil {
+		t.Fatal(keys(defs))
+	}
+	if defs["http.Cookie"].Properties["Expires"].Type != "string" {
+		t.Error("definition should be used")
+	}
+	if b.Schema(reflect.TypeOf(map[string]Cookie{})).Additional.Ref != "#/$defs/jsonschema_test.Cookie" {
+		t.Error("reference should be resolved")
+	}
+}
+
+func keys(m map[string]*jsonschema.Type) []string {
+	var ans []string
+	for k := range m {
+		ans = append(ans, k)
+	}
+	return ans
+}
+
+func TestFor(t *testing.T) {
+	doc := jsonschema.Of[Order](jsonschema.Docs(map[string]string{
+		"github.com/reddec/rpc/jsonschema_test.Order": "Order of cookies.",
+	}))
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Schema      string                     `json:"$schema"`
+		Type        string                     `json:"type"`
+		Description string                     `json:"description"`
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Defs        map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Schema != jsonschema.Draft || parsed.Type != "object" || parsed.Description != "Order of cookies." {
+		t.Fatal(string(data))
+	}
+	if string(parsed.Properties["Local"]) != `{"$ref":"#/$defs/jsonschema_test.Cookie"}` || len(parsed.Defs) != 3 {
+		t.Fatal(string(data))
+	}
+
+	if doc := jsonschema.Of[[]Cookie](); doc.Type.Type != "array" || doc.Defs["Cookie"] == nil {
+		t.Fatal(doc.Type, keys(doc.Defs))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := jsonschema.Handler(map[string]reflect.Type{
+		"order":  reflect.TypeOf(Order{}),
+		"cookie": reflect.TypeOf(Cookie{}),
+	})
+	for path, expected := range map[string]string{
+		"/schemas/":       `["cookie","order"]`,
+		"/schemas/cookie": `{"$schema":"` + jsonschema.Draft + `","type":"object","properties":{"Flavor":{"type":"string"}},"required":["Flavor"]}`,
+	} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		if res.Code != http.StatusOK || res.Body.String() != expected {
+			t.Errorf("%s: %d %s", path, res.Code, res.Body.String())
+		}
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/schemas/unknown", nil))
+	if res.Code != http.StatusNotFound {
+		t.Error(res.Code)
+	}
+}
@@ -0,0 +1,260 @@
+// Package mcp exposes jrpc methods as Model Context Protocol (MCP) tools.
+//
+// Server supports stdio transport ([Server.Serve]) and HTTP transport ([Server.ServeHTTP], JSON responses without
+// server-sent events). Each method is listed as tool (see [jrpc.RPC.Tools]), tool calls are dispatched to methods,
+// and errors returned by methods are mapped to tool error results.
+//
+// Tool descriptions are taken from doc comments of methods: doc comments are not available in runtime, so generate
+// table by rpc-gen with -docs flag and pass it by [jrpc.Docs]. [jrpc.Describe] overrides description of method.
+//
+//	api := jrpc.New(&srv, jrpc.Docs(ServerDocs()), jrpc.Describe("Search", "Search documents by query"))
+//	server := mcp.New(api, mcp.Name("docs", "1.0.0"))
+//	// stdio
+//	err := server.Serve(ctx, os.Stdin, os.Stdout)
+//	// or HTTP
+//	http.Handle("/mcp", server)
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/reddec/rpc/internal/framing"
+	"github.com/reddec/rpc/internal/origin"
+	"github.com/reddec/rpc/jrpc"
+)
+
+// ProtocolVersion is the latest supported version of MCP.
+const ProtocolVersion = "2025-06-18"
+
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Option configures server.
+type Option func(server *Server)
+
+// Name and version of server reported to clients. Default is "rpc" and "0.0.0".
+func Name(name, version string) Option {
+	return func(server *Server) {
+		server.info.Name = name
+		server.info.Version = version
+	}
+}
+
+// Instructions for clients (LLM) how to use server.
+func Instructions(text string) Option {
+	return func(server *Server) {
+		server.instructions = text
+	}
+}
+
+// AllowOrigins allows browser requests of HTTP transport from origins: full origin (ex: http://localhost:6274),
+// host (ex: localhost:6274) or * for any origin. Requests without Origin header (non-browser clients)
+// are always allowed, requests with other origins are rejected to prevent DNS rebinding attacks.
+func AllowOrigins(origins ...string) Option {
+	return func(server *Server) {
+		server.origins = append(server.origins, origins...)
+	}
+}
+
+// New creates MCP server for methods exposed by jrpc.
+func New(api *jrpc.RPC, options ...Option) *Server {
+	server := &Server{api: api}
+	server.info.Name = "rpc"
+	server.info.Version = "0.0.0"
+	for _, opt := range options {
+		opt(server)
+	}
+	return server
+}
+
+// Server of MCP.
+type Server struct {
+	api          *jrpc.RPC
+	instructions string
+	origins      []string // see AllowOrigins
+	info         struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Serve MCP over stdio transport: newline-delimited JSON-RPC messages are read from input and responses are written
+// to output. Requests are handled concurrently. Request-scoped parameters are resolved from synthetic request
+// (without headers). Returns nil at the end of input once all in-flight requests are finished.
+func (s *Server) Serve(ctx context.Context, input io.Reader, output io.Writer) error {
+	base, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+	if err != nil {
+		return err
+	}
+	reader := framing.NewReader(input, false)
+	writer := framing.NewWriter(output, false)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := s.handle(base, data); res != nil {
+				out, _ := json.Marshal(res)
+				_ = writer.Write(out)
+			}
+		}()
+	}
+}
+
+// ServeHTTP implements HTTP transport: single JSON-RPC message in POST body, response as JSON.
+// Notifications and responses are accepted with 202 Accepted. Other methods return 405 Method Not Allowed
+// (server-sent events are not supported). Request is used for request-scoped parameters of methods.
+// Requests with Origin header, which is not allowed by [AllowOrigins], are rejected with 403 Forbidden.
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if !origin.Listed(request, s.origins) {
+		http.Error(writer, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res := s.handle(request, data)
+	if res == nil {
+		writer.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(res)
+}
+
+// handle single JSON-RPC message. Returns nil for notifications.
+func (s *Server) handle(request *http.Request, data []byte) *response {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return failure(nil, codeParseError, err.Error())
+	}
+	if msg.ID == nil {
+		return nil // notification (or response from client)
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		return failure(msg.ID, codeInvalidRequest, "invalid request")
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return success(msg.ID, s.initialize(msg.Params))
+	case "ping":
+		return success(msg.ID, struct{}{})
+	case "tools/list":
+		return success(msg.ID, map[string]any{"tools": s.api.Tools()})
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return failure(msg.ID, codeInvalidParams, err.Error())
+		}
+		result, err := s.api.CallTool(request, params.Name, params.Arguments)
+		if errors.Is(err, jrpc.ErrUnknownTool) {
+			return failure(msg.ID, codeInvalidParams, err.Error())
+		}
+		if err != nil {
+			return success(msg.ID, toolResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true})
+		}
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		return success(msg.ID, toolResult{Content: []content{{Type: "text", Text: string(result)}}})
+	default:
+		return failure(msg.ID, codeMethodNotFound, "method not found: "+msg.Method)
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) any {
+	var req struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &req)
+	version := ProtocolVersion
+	if supportedVersions[req.ProtocolVersion] {
+		version = req.ProtocolVersion
+	}
+	result := map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools": map[string]any{},
+		},
+		"serverInfo": s.info,
+	}
+	if s.instructions != "" {
+		result["instructions"] = s.instructions
+	}
+	return result
+}
+
+func success(id json.RawMessage, result any) *response {
+	return &response{JSONRPC: "2.0", ID: id, Result: result}
+}
+
+func failure(id json.RawMessage, code int, message string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}}
+}
@@ -0,0 +1,199 @@
+package mcp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc/jrpc"
+	"github.com/reddec/rpc/mcp"
+)
+
+type Query struct {
+	Text  string
+	Limit int
+}
+
+type Docs struct{}
+
+func (d *Docs) Search(ctx context.Context, query Query) ([]string, error) {
+	if query.Text == "" {
+		return nil, errors.New("empty query")
+	}
+	return []string{query.Text + "-1", query.Text + "-2"}[:query.Limit], nil
+}
+
+func (d *Docs) Count(tags []string) int {
+	return len(tags)
+}
+
+func (d *Docs) Reset() {}
+
+func newServer() *mcp.Server {
+	api := jrpc.New(&Docs{}, jrpc.Describe("Search", "Search documents"))
+	return mcp.New(api, mcp.Name("docs", "1.0.0"))
+}
+
+func TestServer_Serve(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-03-26"}}`,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "Search", "arguments": {"Text": "go", "Limit": 1}}}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "tools/call", "params": {"name": "Search", "arguments": {"Text": ""}}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "tools/call", "params": {"name": "Count", "arguments": {"payload": ["a", "b"]}}}`,
+		`{"jsonrpc": "2.0", "id": 6, "method": "tools/call", "params": {"name": "Missing"}}`,
+		`{"jsonrpc": "2.0", "id": 7, "method": "unknown"}`,
+	}, "\n")
+	var output bytes.Buffer
+	if err := newServer().Serve(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	var responses = make(map[string]json.RawMessage)
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var msg struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatal(err)
+		}
+		responses[string(msg.ID)] = json.RawMessage(line)
+	}
+	if len(responses) != 7 {
+		t.Fatal("expected 7 responses, got", len(responses), output.String())
+	}
+
+	var initialize struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			ServerInfo      struct {
+				Name string `json:"name"`
+			} `json:"serverInfo"`
+		} `json:"result"`
+	}
+	_ = json.Unmarshal(responses["1"], &initialize)
+	if initialize.Result.ProtocolVersion != "2025-03-26" || initialize.Result.ServerInfo.Name != "docs" {
+		t.Error(string(responses["1"]))
+	}
+
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name        string                     `json:"name"`
+				Description string                     `json:"description"`
+				InputSchema map[string]json.RawMessage `json:"inputSchema"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	_ = json.Unmarshal(responses["2"], &list)
+	if tools := list.Result.Tools; len(tools) != 3 || tools[2].Name != "Search" || tools[2].Description != "Search documents" {
+		t.Fatal(string(responses["2"]))
+	}
+	for _, tool := range list.Result.Tools {
+		if string(tool.InputSchema["type"]) != `"object"` {
+			t.Error(tool.Name, "input should be object")
+		}
+	}
+
+	expected := map[string]string{
+		"3": `{"jsonrpc":"2.0","id":3,"result":{"content":[{"type":"text","text":"[\"go-1\"]"}]}}`,
+		"4": `{"jsonrpc":"2.0","id":4,"result":{"content":[{"type":"text","text":"empty query"}],"isError":true}}`,
+		"5": `{"jsonrpc":"2.0","id":5,"result":{"content":[{"type":"text","text":"2"}]}}`,
+	}
+	for id, value := range expected {
+		if string(responses[id]) != value {
+			t.Error(id, string(responses[id]))
+		}
+	}
+	if !strings.Contains(string(responses["6"]), `"code":-32602`) || !strings.Contains(string(responses["7"]), `"code":-32601`) {
+		t.Error(string(responses["6"]), string(responses["7"]))
+	}
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	server := newServer()
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(
+		`{"jsonrpc": "2.0", "id": "a", "method": "tools/call", "params": {"name": "Reset"}}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"jsonrpc":"2.0","id":"a","result":{"content":[{"type":"text","text":"null"}]}}` {
+		t.Error(rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Error(rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error(rec.Code)
+	}
+}
+
+func TestServer_ServeHTTP_origin(t *testing.T) {
+	api := jrpc.New(&Docs{})
+	for _, tc := range []struct {
+		origin  string
+		allowed []string
+		status  int
+	}{
+		{origin: "", status: http.StatusAccepted},
+		{origin: "http://evil.example.com", status: http.StatusForbidden},
+		{origin: "http://example.com", status: http.StatusForbidden}, // same host as request, but may be rebound
+		{origin: "http://localhost:6274", allowed: []string{"http://localhost:6274"}, status: http.StatusAccepted},
+		{origin: "http://localhost:6274", allowed: []string{"localhost:6274"}, status: http.StatusAccepted},
+		{origin: "http://evil.example.com", allowed: []string{"*"}, status: http.StatusAccepted},
+	} {
+		server := mcp.New(api, mcp.AllowOrigins(tc.allowed...))
+		request := httptest.NewRequest(http.MethodPost, "http://example.com/mcp", strings.NewReader(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`))
+		if tc.origin != "" {
+			request.Header.Set("Origin", tc.origin)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, request)
+		if rec.Code != tc.status {
+			t.Error(tc.origin, tc.allowed, rec.Code)
+		}
+	}
+}
+
+func TestServer_docs(t *testing.T) {
+	// table as generated by rpc-gen -docs from doc comments
+	docs := map[string]string{
+		"github.com/reddec/rpc/mcp_test.Docs.Count": "Count tags",
+		"Search": "Find documents",
+	}
+	api := jrpc.New(&Docs{}, jrpc.Docs(docs), jrpc.Describe("Search", "Search documents"))
+	server := mcp.New(api)
+	request := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, request)
+
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err, rec.Body.String())
+	}
+	var descriptions = make(map[string]string)
+	for _, tool := range list.Result.Tools {
+		descriptions[tool.Name] = tool.Description
+	}
+	if descriptions["Count"] != "Count tags" || descriptions["Search"] != "Search documents" || descriptions["Reset"] != "" {
+		t.Fatal(descriptions)
+	}
+}
@@ -0,0 +1,104 @@
+// Package naming defines how Go method names are converted to endpoint names.
+//
+// The same strategy must be used by server (rpc, jrpc), schema generators and clients,
+// otherwise paths will not match.
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Strategy converts Go method name to endpoint name.
+type Strategy func(name string) string
+
+var (
+	// AsIs keeps name unchanged (case-sensitive): MyFoo -> MyFoo.
+	AsIs Strategy = func(name string) string { return name }
+	// Lower converts name to lower case: MyFoo -> myfoo.
+	Lower Strategy = strings.ToLower
+	// Camel converts name to camelCase: MyFoo -> myFoo, HTTPServer -> httpServer.
+	Camel Strategy = camel
+	// Snake converts name to snake_case: MyFoo -> my_foo, GetUserID -> get_user_id.
+	Snake Strategy = func(name string) string { return join(name, "_") }
+	// Kebab converts name to kebab-case: MyFoo -> my-foo, GetUserID -> get-user-id.
+	Kebab Strategy = func(name string) string { return join(name, "-") }
+)
+
+// Parse strategy by name: lower, camel, snake, kebab, as-is (or asis). Empty name means lower.
+func Parse(name string) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "", "lower":
+		return Lower, nil
+	case "camel":
+		return Camel, nil
+	case "snake":
+		return Snake, nil
+	case "kebab":
+		return Kebab, nil
+	case "as-is", "asis":
+		return AsIs, nil
+	default:
+		return nil, fmt.Errorf("unknown naming strategy %q", name)
+	}
+}
+
+// Words splits Go identifier to words. Sequence of upper-case letters is treated as
+// single word (acronym): GetHTTPServer -> Get, HTTP, Server.
+func Words(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		var boundary bool
+		switch {
+		case cur == '_' || cur == '-':
+			boundary = true
+		case unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// fooBar, foo1Bar
+			boundary = true
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// HTTPServer
+			boundary = true
+		}
+		if !boundary {
+			continue
+		}
+		words = appendWord(words, runes[start:i])
+		start = i
+	}
+	words = appendWord(words, runes[start:])
+	return words
+}
+
+func appendWord(words []string, word []rune) []string {
+	w := strings.Trim(string(word), "_-")
+	if w == "" {
+		return words
+	}
+	return append(words, w)
+}
+
+func join(name string, sep string) string {
+	words := Words(name)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return strings.Join(words, sep)
+}
+
+func camel(name string) string {
+	words := Words(name)
+	if len(words) == 0 {
+		return ""
+	}
+	words[0] = strings.ToLower(words[0])
+	for i := 1; i < len(words); i++ {
+		r := []rune(words[i])
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
@@ -0,0 +1,54 @@
+package naming_test
+
+import (
+	"testing"
+
+	"github.com/reddec/rpc/naming"
+)
+
+func TestStrategies(t *testing.T) {
+	cases := []struct {
+		name  string
+		lower string
+		camel string
+		snake string
+		kebab string
+	}{
+		{"MyFoo", "myfoo", "myFoo", "my_foo", "my-foo"},
+		{"GetHTTPServer", "gethttpserver", "getHTTPServer", "get_http_server", "get-http-server"},
+		{"getUserID", "getuserid", "getUserID", "get_user_id", "get-user-id"},
+		{"HTTP2Server", "http2server", "http2Server", "http2_server", "http2-server"},
+		{"Foo12Bar", "foo12bar", "foo12Bar", "foo12_bar", "foo12-bar"},
+		{"sum", "sum", "sum", "sum", "sum"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if v := naming.AsIs(c.name); v != c.name {
+				t.Error("as-is:", v)
+			}
+			if v := naming.Lower(c.name); v != c.lower {
+				t.Error("lower:", v)
+			}
+			if v := naming.Camel(c.name); v != c.camel {
+				t.Error("camel:", v)
+			}
+			if v := naming.Snake(c.name); v != c.snake {
+				t.Error("snake:", v)
+			}
+			if v := naming.Kebab(c.name); v != c.kebab {
+				t.Error("kebab:", v)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"", "lower", "camel", "snake", "kebab", "as-is", "asis", "SNAKE"} {
+		if _, err := naming.Parse(name); err != nil {
+			t.Error(name, err)
+		}
+	}
+	if _, err := naming.Parse("pascal"); err == nil {
+		t.Error("unknown strategy should fail")
+	}
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// plan of method call precomputed on indexing: positions of parameters in method signature by source
+// and pool of argument slices.
+type plan struct {
+	payload   []int // positions of payload arguments in order of payload
+	files     []int // positions of files in order of files
+	providers []int // positions of request-scoped parameters
+	pool      sync.Pool
+}
+
+func newPlan(inputs []input) *plan {
+	p := &plan{}
+	for i := 1; i < len(inputs); i++ {
+		switch in := inputs[i]; {
+		case in.provider != nil:
+			p.providers = append(p.providers, i)
+		case in.file:
+			p.files = append(p.files, i)
+		default:
+			p.payload = append(p.payload, i)
+		}
+	}
+	size := len(inputs)
+	p.pool.New = func() any {
+		values := make([]reflect.Value, size)
+		return &values
+	}
+	return p
+}
+
+// get arguments from pool. Pointer is used to avoid allocation on put.
+func (p *plan) get() *[]reflect.Value {
+	return p.pool.Get().(*[]reflect.Value)
+}
+
+// put arguments back to pool. Values are cleared to not retain them.
+func (p *plan) put(values *[]reflect.Value) {
+	for i := range *values {
+		(*values)[i] = reflect.Value{}
+	}
+	p.pool.Put(values)
+}
+
+var paramsType = reflect.TypeOf([]json.RawMessage{})
+
+// skipValue consumes JSON value without allocation (used for extra arguments).
+type skipValue struct{}
+
+func (skipValue) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+// tokenKind is name of JSON value kind as in [json.UnmarshalTypeError].
+func tokenKind(token json.Token) string {
+	switch token.(type) {
+	case json.Delim:
+		return "object"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	default:
+		return "number"
+	}
+}
+
+// rawPayload is reader of JSON payload, where empty payload is null.
+func rawPayload(data []byte) io.Reader {
+	if len(data) == 0 {
+		return bytes.NewReader(nullPayload)
+	}
+	return bytes.NewReader(data)
+}
+
+var nullPayload = []byte("null")
+
+// maxPooledBuffer is maximum size of buffer returned to pool, larger buffers are released.
+const maxPooledBuffer = 64 << 10
+
+type jsonEncoder struct {
+	buffer  bytes.Buffer
+	encoder *json.Encoder
+}
+
+var encoders = sync.Pool{
+	New: func() any {
+		e := &jsonEncoder{}
+		e.encoder = json.NewEncoder(&e.buffer)
+		e.encoder.SetIndent("", "  ")
+		return e
+	},
+}
+
+func (e *jsonEncoder) release() {
+	if e.buffer.Cap() <= maxPooledBuffer {
+		e.buffer.Reset()
+		encoders.Put(e)
+	}
+}
+
+// writeJSON writes indented JSON value using pooled buffer and encoder.
+func writeJSON(writer http.ResponseWriter, value any) {
+	e := encoders.Get().(*jsonEncoder)
+	defer e.release()
+	if err := e.encoder.Encode(value); err != nil {
+		return // too late to do anything
+	}
+	_, _ = writer.Write(e.buffer.Bytes())
+}
+
+// encodeJSON returns the same content as written by writeJSON.
+func encodeJSON(value any) ([]byte, error) {
+	e := encoders.Get().(*jsonEncoder)
+	defer e.release()
+	if err := e.encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buffer.Bytes()...), nil
+}
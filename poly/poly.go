@@ -0,0 +1,214 @@
+// Package poly describes polymorphic values: implementations of interface distinguished by discriminator property.
+//
+//	type Shape interface{ Area() float64 }
+//
+//	func init() {
+//		poly.Define[Shape]("type",
+//			poly.Variant[*Circle]("circle"),
+//			poly.Variant[*Square]("square"),
+//		)
+//	}
+//
+//	type Drawing struct {
+//		Shapes []poly.Value[Shape] // [{"type": "circle", "Radius": 1}, ...]
+//	}
+//
+// Only values wrapped by [Value] are encoded with discriminator and can be decoded. Fields, arguments and results
+// of plain interface type (ex: Main Shape) are handled by encoding/json as-is: encoded without discriminator and
+// not decodable, so schema generators describe them as any value. Use Value[Shape] instead.
+//
+// Schema generators (packages jsonschema, schema and jrpc) describe Value of registered interface as oneOf
+// with discriminator; rpc-ts discovers poly.Define calls (with constant arguments) in the package of the service
+// and packages imported by it, and generates discriminated union.
+package poly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Union is registered interface with implementations.
+type Union struct {
+	Interface reflect.Type
+	Property  string                  // discriminator property
+	Variants  map[string]reflect.Type // by value of discriminator
+	names     map[reflect.Type]string
+}
+
+// Names of variants in alphabetical order.
+func (u *Union) Names() []string {
+	var names = make([]string, 0, len(u.Variants))
+	for name := range u.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VariantOption is implementation of interface, see [Variant].
+type VariantOption struct {
+	name string
+	t    reflect.Type
+}
+
+// Variant is implementation T (usually pointer to struct) with value of discriminator property.
+func Variant[T any](name string) VariantOption {
+	return VariantOption{name: name, t: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+var (
+	lock   sync.RWMutex
+	unions = make(map[reflect.Type]*Union)
+)
+
+// Define registers implementations of interface I with discriminator property. Implementations should encode
+// as JSON objects and should not have field with the same name as discriminator. It panics if I is not interface,
+// I is already defined, variant doesn't implement I, or name or type of variant is used twice. Usually called
+// from init.
+func Define[I any](property string, variants ...VariantOption) {
+	t := reflect.TypeOf((*I)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic("poly: " + t.String() + " is not interface")
+	}
+	u := &Union{
+		Interface: t,
+		Property:  property,
+		Variants:  make(map[string]reflect.Type, len(variants)),
+		names:     make(map[reflect.Type]string, len(variants)),
+	}
+	for _, v := range variants {
+		if !v.t.Implements(t) {
+			panic("poly: " + v.t.String() + " does not implement " + t.String())
+		}
+		if _, ok := u.Variants[v.name]; ok {
+			panic("poly: variant " + strconv.Quote(v.name) + " of " + t.String() + " is defined twice")
+		}
+		if _, ok := u.names[v.t]; ok {
+			panic("poly: " + v.t.String() + " is defined twice as variant of " + t.String())
+		}
+		u.Variants[v.name] = v.t
+		u.names[v.t] = v.name
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := unions[t]; ok {
+		panic("poly: " + t.String() + " is already defined")
+	}
+	unions[t] = u
+}
+
+// Lookup finds union by registered interface or by [Value] of registered interface.
+func Lookup(t reflect.Type) (*Union, bool) {
+	if holder, ok := reflect.Zero(t).Interface().(interface{ union() reflect.Type }); ok {
+		t = holder.union()
+	}
+	lock.RLock()
+	defer lock.RUnlock()
+	u, ok := unions[t]
+	return u, ok
+}
+
+// Value of interface I, encoded with discriminator property. Interface must be registered by [Define]; Value of
+// non-interface type is neither encoded nor decoded (returns error).
+type Value[I any] struct {
+	Value I
+}
+
+// Of wraps value.
+func Of[I any](value I) Value[I] {
+	return Value[I]{Value: value}
+}
+
+func (v Value[I]) union() reflect.Type {
+	return reflect.TypeOf((*I)(nil)).Elem()
+}
+
+func (v Value[I]) registered() (*Union, error) {
+	if v.union().Kind() != reflect.Interface {
+		return nil, errors.New("poly: " + v.union().String() + " is not interface")
+	}
+	u, ok := Lookup(v.union())
+	if !ok {
+		return nil, errors.New("poly: " + v.union().String() + " is not defined")
+	}
+	return u, nil
+}
+
+// MarshalJSON encodes value as object with discriminator property first. Nil value is encoded as null.
+func (v Value[I]) MarshalJSON() ([]byte, error) {
+	value := reflect.ValueOf(&v.Value).Elem()
+	if value.Kind() == reflect.Interface && value.IsNil() {
+		return []byte("null"), nil
+	}
+	u, err := v.registered()
+	if err != nil {
+		return nil, err
+	}
+	concrete := value.Elem()
+	name, ok := u.names[concrete.Type()]
+	if !ok {
+		return nil, fmt.Errorf("poly: %s is not variant of %s", concrete.Type(), u.Interface)
+	}
+	data, err := json.Marshal(concrete.Interface())
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("poly: %s is not encoded as object", concrete.Type())
+	}
+	header, err := json.Marshal(map[string]string{u.Property: name})
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, []byte("{}")) {
+		return header, nil
+	}
+	return append(append(header[:len(header)-1], ','), data[1:]...), nil
+}
+
+// UnmarshalJSON decodes variant by discriminator property.
+func (v *Value[I]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		var zero I
+		v.Value = zero
+		return nil
+	}
+	u, err := v.registered()
+	if err != nil {
+		return err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	var name string
+	if err := json.Unmarshal(object[u.Property], &name); err != nil || name == "" {
+		return fmt.Errorf("poly: discriminator %q of %s is not set", u.Property, u.Interface)
+	}
+	t, ok := u.Variants[name]
+	if !ok {
+		return fmt.Errorf("poly: unknown variant %q of %s", name, u.Interface)
+	}
+
+	var target reflect.Value
+	if t.Kind() == reflect.Ptr {
+		target = reflect.New(t.Elem())
+		err = json.Unmarshal(data, target.Interface())
+	} else {
+		ptr := reflect.New(t)
+		err = json.Unmarshal(data, ptr.Interface())
+		target = ptr.Elem()
+	}
+	if err != nil {
+		return err
+	}
+	v.Value = target.Interface().(I)
+	return nil
+}
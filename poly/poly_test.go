@@ -0,0 +1,157 @@
+package poly_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/reddec/rpc/poly"
+)
+
+type Shape interface{ Area() float64 }
+
+type Circle struct{ Radius float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type Square struct{ Side float64 }
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+type Empty struct{}
+
+func (Empty) Area() float64 { return 0 }
+
+type Drawing struct {
+	Shapes []poly.Value[Shape]
+	Main   *poly.Value[Shape] `json:",omitempty"`
+}
+
+func init() {
+	poly.Define[Shape]("type",
+		poly.Variant[*Circle]("circle"),
+		poly.Variant[Square]("square"),
+		poly.Variant[Empty]("empty"),
+	)
+}
+
+func TestValue(t *testing.T) {
+	drawing := Drawing{Shapes: []poly.Value[Shape]{
+		poly.Of[Shape](&Circle{Radius: 1}),
+		poly.Of[Shape](Square{Side: 2}),
+		poly.Of[Shape](Empty{}),
+		{},
+	}}
+	data, err := json.Marshal(drawing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"Shapes":[{"type":"circle","Radius":1},{"type":"square","Side":2},{"type":"empty"},null]}`
+	if string(data) != expected {
+		t.Fatalf("unexpected encoding %s", data)
+	}
+
+	var decoded Drawing
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := decoded.Shapes[0].Value.(*Circle); !ok || c.Radius != 1 {
+		t.Fatalf("circle expected, got %#v", decoded.Shapes[0].Value)
+	}
+	if s, ok := decoded.Shapes[1].Value.(Square); !ok || s.Side != 2 {
+		t.Fatalf("square expected, got %#v", decoded.Shapes[1].Value)
+	}
+	if _, ok := decoded.Shapes[2].Value.(Empty); !ok {
+		t.Fatalf("empty expected, got %#v", decoded.Shapes[2].Value)
+	}
+	if decoded.Shapes[3].Value != nil {
+		t.Fatalf("nil expected, got %#v", decoded.Shapes[3].Value)
+	}
+}
+
+func TestValue_errors(t *testing.T) {
+	var value poly.Value[Shape]
+	for _, payload := range []string{`{"Radius": 1}`, `{"type": "triangle"}`, `{"type": 1}`, `[]`} {
+		if err := json.Unmarshal([]byte(payload), &value); err == nil {
+			t.Errorf("%s should not be decoded", payload)
+		}
+	}
+
+	type unknown struct{ Shape }
+	if _, err := json.Marshal(poly.Of[Shape](unknown{})); err == nil {
+		t.Error("unregistered variant should not be encoded")
+	}
+	if _, err := json.Marshal(poly.Of[error](nil)); err != nil {
+		t.Errorf("nil value of undefined interface should be encoded as null: %v", err)
+	}
+	var undefined poly.Value[error]
+	if err := json.Unmarshal([]byte(`{}`), &undefined); err == nil {
+		t.Error("undefined interface should not be decoded")
+	}
+
+	if _, err := json.Marshal(poly.Of(Square{Side: 1})); err == nil {
+		t.Error("value of non-interface type should not be encoded")
+	}
+	if _, err := json.Marshal(poly.Of[*Circle](nil)); err == nil {
+		t.Error("nil pointer of non-interface type should not be encoded")
+	}
+	var concrete poly.Value[Square]
+	if err := json.Unmarshal([]byte(`{"type": "square"}`), &concrete); err == nil {
+		t.Error("value of non-interface type should not be decoded")
+	}
+}
+
+type Animal interface{ Sound() string }
+
+type Dog struct{}
+
+func (Dog) Sound() string { return "woof" }
+
+type Cat struct{}
+
+func (Cat) Sound() string { return "meow" }
+
+func TestDefine_duplicates(t *testing.T) {
+	cases := map[string]func(){
+		"name": func() {
+			poly.Define[Animal]("kind", poly.Variant[Dog]("pet"), poly.Variant[Cat]("pet"))
+		},
+		"type": func() {
+			poly.Define[Animal]("kind", poly.Variant[Dog]("dog"), poly.Variant[Dog]("puppy"))
+		},
+		"interface": func() {
+			poly.Define[Shape]("kind", poly.Variant[*Circle]("circle"))
+		},
+		"non-interface": func() {
+			poly.Define[Square]("kind")
+		},
+	}
+	for name, define := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("panic expected")
+				}
+			}()
+			define()
+		})
+	}
+	if _, ok := poly.Lookup(reflect.TypeOf((*Animal)(nil)).Elem()); ok {
+		t.Fatal("invalid definition should not be registered")
+	}
+	if union, _ := poly.Lookup(reflect.TypeOf((*Shape)(nil)).Elem()); union.Property != "type" {
+		t.Fatal("definition should not be replaced")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, typ := range []reflect.Type{reflect.TypeOf((*Shape)(nil)).Elem(), reflect.TypeOf(poly.Value[Shape]{})} {
+		union, ok := poly.Lookup(typ)
+		if !ok || union.Property != "type" || len(union.Names()) != 3 || union.Names()[0] != "circle" {
+			t.Fatalf("union of %s not found: %+v", typ, union)
+		}
+	}
+	if _, ok := poly.Lookup(reflect.TypeOf(Drawing{})); ok {
+		t.Fatal("struct is not union")
+	}
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"reflect"
+)
+
+// Registry combines multiple services under namespaces into single index, which can be used
+// as regular index: exposed by [Router] or documented by schema package.
+//
+//	index := rpc.NewRegistry().
+//		Add("users", &users).     // Create(...) -> POST /users.create
+//		Add("billing", &billing). // Invoice(...) -> POST /billing.invoice
+//		Index()
+//
+//	http.Handle("/api/", http.StripPrefix("/api", rpc.Router(index)))
+//
+// Keys in index are namespace.Name (for example: users.Create).
+type Registry struct {
+	options   []Option
+	index     map[string]*ExposedMethod
+	endpoints map[string]string   // endpoint name (or alias) -> method key
+	aliases   map[string][]string // aliases of all services by method key
+}
+
+// NewRegistry creates empty registry. Options will be applied for each added service.
+func NewRegistry(options ...Option) *Registry {
+	return &Registry{
+		options:   options,
+		index:     make(map[string]*ExposedMethod),
+		endpoints: make(map[string]string),
+		aliases:   make(map[string][]string),
+	}
+}
+
+// Add service under namespace (used as-is). Empty namespace means root level.
+// Options are applied after registry options. See [Index] for details.
+// It panics if key or any of endpoint names (including aliases) is already registered, so services can't
+// shadow methods of each other.
+func (r *Registry) Add(namespace string, object interface{}, options ...Option) *Registry {
+	cfg := newConfig(append(r.options[:len(r.options):len(r.options)], options...))
+	index := make(map[string]*ExposedMethod)
+	indexObject(index, cfg, namespace, reflect.ValueOf(object), nil, nil, nil)
+	for key := range index {
+		if _, exists := r.index[key]; exists {
+			panic("rpc: method " + key + " already registered")
+		}
+	}
+	addEndpoints(r.endpoints, index)
+	for key, method := range index {
+		r.index[key] = method
+	}
+	for key, names := range cfg.aliases {
+		r.aliases[key] = names
+	}
+	return r
+}
+
+// Index of all registered methods. It panics if alias (of any service) refers to unknown method.
+func (r *Registry) Index() map[string]*ExposedMethod {
+	checkAliases(r.index, r.aliases)
+	return r.index
+}
@@ -0,0 +1,137 @@
+package rpc_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type usersService struct {
+	prefix string
+}
+
+func (us *usersService) Create(name string) string {
+	return us.prefix + name
+}
+
+type invoicer interface {
+	Invoice(amount int) int
+}
+
+type billingService struct{}
+
+func (bs *billingService) Invoice(amount int) int {
+	return amount * 2
+}
+
+func (bs *billingService) Internal() {}
+
+type rootService struct {
+	Users   *usersService
+	Billing invoicer      `rpc:"bill"`
+	Hidden  *usersService `rpc:"-"`
+	Empty   *usersService
+}
+
+func (rs *rootService) Version() string {
+	return "1"
+}
+
+func call(t *testing.T, handler http.Handler, path string, payload string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(payload))
+	handler.ServeHTTP(rec, req)
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestRegistry(t *testing.T) {
+	index := rpc.NewRegistry().
+		Add("users", &usersService{prefix: "user:"}).
+		Add("billing", &billingService{}).
+		Index()
+
+	if _, ok := index["users.Create"]; !ok {
+		t.Fatal("namespaced method should be indexed")
+	}
+	if ns := index["users.Create"].Namespace(); ns != "users" {
+		t.Error(ns)
+	}
+
+	router := rpc.Router(index)
+	if code, body := call(t, router, "/users.create", `["alice"]`); code != http.StatusOK || body != `"user:alice"` {
+		t.Error(code, body)
+	}
+	if code, body := call(t, router, "/billing.invoice", `[2]`); code != http.StatusOK || body != `4` {
+		t.Error(code, body)
+	}
+
+	t.Run("duplicates should panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("should panic")
+			}
+		}()
+		rpc.NewRegistry().Add("users", &usersService{}).Add("users", &usersService{})
+	})
+
+	t.Run("aliases should not shadow endpoints", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("should panic")
+			}
+		}()
+		rpc.NewRegistry().
+			Add("users", &usersService{}).
+			Add("billing", &billingService{}, rpc.Alias("billing.Invoice", "users.create"))
+	})
+}
+
+func TestNested(t *testing.T) {
+	root := &rootService{
+		Users:   &usersService{prefix: "user:"},
+		Billing: &billingService{},
+		Hidden:  &usersService{},
+	}
+	index := rpc.Index(root, rpc.Nested())
+	for _, key := range []string{"Version", "users.Create", "bill.Invoice", "empty.Create"} {
+		if _, ok := index[key]; !ok {
+			t.Error(key, "should be indexed")
+		}
+	}
+	for _, key := range []string{"bill.Internal", "hidden.Create"} {
+		if _, ok := index[key]; ok {
+			t.Error(key, "should not be indexed")
+		}
+	}
+
+	router := rpc.Router(index)
+	if code, body := call(t, router, "/users.create", `["bob"]`); code != http.StatusOK || body != `"user:bob"` {
+		t.Error(code, body)
+	}
+	if code, body := call(t, router, "/bill.invoice", `[3]`); code != http.StatusOK || body != `6` {
+		t.Error(code, body)
+	}
+
+	t.Run("builder", func(t *testing.T) {
+		handler := rpc.Builder(func(r *http.Request) (*rootService, error) {
+			return &rootService{Users: &usersService{prefix: r.Header.Get("X-Prefix")}}, nil
+		}, rpc.Nested())
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users.create", bytes.NewBufferString(`["carl"]`))
+		req.Header.Set("X-Prefix", "session:")
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `"session:carl"` {
+			t.Error(rec.Code, rec.Body.String())
+		}
+
+		if code, _ := call(t, handler, "/empty.create", `["dan"]`); code != http.StatusInternalServerError {
+			t.Error("nil service should return 500, got", code)
+		}
+	})
+}
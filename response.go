@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+type responseKey struct{}
+
+// Response controls response metadata (headers, cookies and success status) from inside method.
+// Controller is available in method context for rpc and jrpc handlers, see [ResponseFrom].
+//
+//	func (srv *Server) Login(ctx context.Context, user, password string) error {
+//		// ...
+//		rpc.ResponseFrom(ctx).SetCookie(&http.Cookie{Name: "session", Value: token})
+//		return nil
+//	}
+//
+// Headers and cookies are applied for any response (including errors), status is applied only for successful calls.
+type Response struct {
+	header http.Header
+	status int
+}
+
+// NewResponse creates empty response controller.
+func NewResponse() *Response {
+	return &Response{header: make(http.Header)}
+}
+
+// WithResponse returns copy of context with response controller.
+// It's used by handlers and rarely needed to be called directly.
+func WithResponse(ctx context.Context, response *Response) context.Context {
+	return context.WithValue(ctx, responseKey{}, response)
+}
+
+// ResponseFrom returns response controller from context. If context has no controller (for example,
+// method called directly, not by handler), detached controller returned, so changes are safely ignored.
+func ResponseFrom(ctx context.Context) *Response {
+	if r, ok := ctx.Value(responseKey{}).(*Response); ok {
+		return r
+	}
+	return NewResponse()
+}
+
+// Header which will be added to response.
+func (r *Response) Header() http.Header {
+	return r.header
+}
+
+// SetCookie adds Set-Cookie header to response.
+func (r *Response) SetCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		r.header.Add("Set-Cookie", v)
+	}
+}
+
+// SetStatus sets custom status code for successful response (ex: 201 Created).
+func (r *Response) SetStatus(code int) {
+	r.status = code
+}
+
+// Status code set by method or 0.
+func (r *Response) Status() int {
+	return r.status
+}
+
+// Apply copies headers (and cookies) to writer. Should be called before writing status.
+func (r *Response) Apply(writer http.ResponseWriter) {
+	dest := writer.Header()
+	for k, v := range r.header {
+		dest[k] = append(dest[k], v...)
+	}
+}
+
+// StatusOr returns custom status code if set, otherwise defaultStatus.
+func (r *Response) StatusOr(defaultStatus int) int {
+	if r.status != 0 {
+		return r.status
+	}
+	return defaultStatus
+}
@@ -0,0 +1,66 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type sessionAPI struct{}
+
+func (sa *sessionAPI) Login(ctx context.Context, user string) (string, error) {
+	response := rpc.ResponseFrom(ctx)
+	response.Header().Set("X-User", user)
+	if user == "" {
+		return "", errors.New("empty user")
+	}
+	response.SetCookie(&http.Cookie{Name: "session", Value: user})
+	response.SetStatus(http.StatusCreated)
+	return "welcome", nil
+}
+
+func TestResponseFrom(t *testing.T) {
+	router := rpc.New(&sessionAPI{})
+
+	t.Run("success", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString(`["reddec"]`))
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Error(rec.Code, rec.Body.String())
+		}
+		if v := rec.Header().Get("X-User"); v != "reddec" {
+			t.Error(v)
+		}
+		if v := rec.Header().Get("Set-Cookie"); v != "session=reddec" {
+			t.Error(v)
+		}
+		if v := rec.Header().Get("Content-Type"); v != "application/json" {
+			t.Error(v)
+		}
+	})
+
+	t.Run("error keeps headers but not status", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString(`[""]`))
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusInternalServerError {
+			t.Error(rec.Code, rec.Body.String())
+		}
+		if _, ok := rec.Header()["X-User"]; !ok {
+			t.Error("header should be set")
+		}
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		v, err := (&sessionAPI{}).Login(context.Background(), "direct")
+		if err != nil || v != "welcome" {
+			t.Error(v, err)
+		}
+	})
+}
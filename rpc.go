@@ -1,13 +1,20 @@
 package rpc
 
 import (
-	"context"
 	_ "embed"
-	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/reddec/rpc/internal/download"
+	"github.com/reddec/rpc/internal/inject"
+	"github.com/reddec/rpc/internal/upload"
+	"github.com/reddec/rpc/naming"
 )
 
 // JS is embedded content of supporting script. Can be served as-is.
@@ -30,34 +37,106 @@ func Script() http.Handler {
 // # Supported methods
 //
 // Criteria for matching methods: no return values, or single return value/error, or two return values, where second one
-// must be an error. First input argument could be context.Context which will be automatically wired from request.Context().
+// must be an error. Request-scoped parameters (context.Context, *http.Request, http.Header and types registered by [Inject])
+// will be automatically wired from request and excluded from payload.
 //
 //		Foo()                                          // OK
 //		Foo(ctx context.Context)                       // OK
 //		Foo(ctx context.Context, bar int, baz SomeObj) // OK
 //		Foo(bar int, baz string)                       // OK
+//		Foo(bar int, headers http.Header)              // OK, payload is [bar]
+//		Foo(name string, content io.Reader)            // OK, multipart request, see below
+//
+//		Foo(...) error          // OK
+//		Foo(...) int            // OK
+//		Foo(...) (int, error)   // OK
+//		Foo(...) (*Blob, error) // OK, raw content, see below
+//	    Foo(...) (int, int)     // NOT ok - last argument is not an error
+//
+// # Files
+//
+// Arguments of type io.Reader, *multipart.FileHeader or [File] are filled from multipart/form-data request:
+// part "args" contains JSON array of other arguments, parts "file0", "file1", ... contain files in order of arguments.
+// Content is streamed: "args" part must be first and files must follow in order. Only the last file is streamed
+// directly from request, others are stored to temporary files. If any of arguments is *multipart.FileHeader,
+// the whole form is parsed by [http.Request.ParseMultipartForm]. Size of "args" part and of temporary files
+// is limited by [MaxUploadArgs] and [MaxUploadSpool], larger parts cause 413 Request Entity Too Large.
+//
+// # Downloads
+//
+// Results implementing io.Reader or io.WriterTo (for example io.ReadCloser, *os.File or [Blob]) are streamed as raw
+// content instead of JSON. [Blob] sets Content-Type and Content-Disposition. Seekable content (io.ReadSeeker) supports
+// Range and conditional requests, unless custom status is set. Content is closed after writing if it implements io.Closer.
 //
-//		Foo(...) error        // OK
-//		Foo(...) int          // OK
-//		Foo(...) (int, error) // OK
-//	    Foo(...) (int, int)   // NOT ok - last argument is not an error
+// # Streams
+//
+// Results of channel type (<-chan T) are streams: items are written as newline-delimited JSON (application/x-ndjson)
+// until channel is closed or request is canceled. Over websocket ([WebSocket]) items are pushed as separate messages.
+// Method should stop producing items once context is done.
 //
 // # Status codes
 //
 // - 400 Bad Request in case payload can not be unmarshalled to arguments or number of arguments not enough.
-// - 500 Internal Server Error in case method returned an error. Response payload will be error message (plain text)
-// - 200 OK in case everything fine
-func Index(object interface{}) map[string]*ExposedMethod {
-	value := reflect.ValueOf(object)
+// - 413 Request Entity Too Large in case part of multipart request exceeds limit, see [MaxUploadArgs]
+// - 500 Internal Server Error in case method returned an error or provider of parameter returned an error. Response payload will be error message (plain text)
+// - 200 OK in case everything fine, unless method set custom status by [ResponseFrom]
+// - 202 Accepted with [JobState] for asynchronous methods, see [Async]
+//
+// # Naming
+//
+// Endpoint name is derived from method name by [naming.Strategy] (default is [naming.Lower]), see [Naming] and [Alias].
+// Index panics if endpoint names (including aliases) of different methods collide or alias refers to unknown method.
+func Index(object interface{}, options ...Option) map[string]*ExposedMethod {
+	cfg := newConfig(options)
+	res := make(map[string]*ExposedMethod)
+	indexObject(res, cfg, "", reflect.ValueOf(object), nil, nil, nil)
+	addEndpoints(make(map[string]string), res)
+	checkAliases(res, cfg.aliases)
+	return res
+}
+
+// addEndpoints registers endpoint names (including aliases) of methods by key.
+// It panics if name is already used by another method.
+func addEndpoints(endpoints map[string]string, index map[string]*ExposedMethod) {
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		method := index[key]
+		for _, name := range append([]string{method.Endpoint()}, method.Aliases()...) {
+			if other, ok := endpoints[name]; ok && other != key {
+				panic("rpc: endpoint " + name + " of " + key + " conflicts with " + other)
+			}
+			endpoints[name] = key
+		}
+	}
+}
+
+// checkAliases panics if alias refers to method (by key) which is not in index.
+func checkAliases(index map[string]*ExposedMethod, aliases map[string][]string) {
+	for key, names := range aliases {
+		if _, ok := index[key]; !ok {
+			panic("rpc: alias " + strings.Join(names, ", ") + " refers to unknown method " + key)
+		}
+	}
+}
+
+// indexObject adds matched methods of value to index. Namespace (if not empty) is prefixed to keys and endpoints.
+// Path is chain of fields from root object used to resolve receiver for nested services.
+// If allowed is not nil, only listed methods will be indexed (used for interface fields).
+func indexObject(res map[string]*ExposedMethod, cfg *config, namespace string, value reflect.Value, path []int, allowed map[string]bool, visited []reflect.Type) {
 	t := value.Type()
 	errorInterface := reflect.TypeOf((*error)(nil)).Elem()
-	ctxInterface := reflect.TypeOf((*context.Context)(nil)).Elem()
 
-	res := make(map[string]*ExposedMethod)
 	n := t.NumMethod()
 
 	for i := 0; i < n; i++ {
 		method := t.Method(i)
+		if allowed != nil && !allowed[method.Name] {
+			continue
+		}
 
 		args := method.Type.NumIn()
 		out := method.Type.NumOut()
@@ -73,52 +152,219 @@ func Index(object interface{}) map[string]*ExposedMethod {
 			responseType = method.Type.Out(0)
 		}
 
-		hasContext := args > 1 && method.Type.In(1).Implements(ctxInterface)
+		// request-scoped parameters are resolved by providers, files are taken from multipart request,
+		// others are taken from payload
+		var argTypes, fileTypes []reflect.Type
+		var inputs = make([]input, args)
+		for arg := 1; arg < args; arg++ {
+			argType := method.Type.In(arg)
+			if provider, ok := cfg.providers[argType]; ok {
+				inputs[arg].provider = provider
+				continue
+			}
+			if upload.IsFile(argType) {
+				inputs[arg].file = true
+				fileTypes = append(fileTypes, argType)
+				continue
+			}
+			argTypes = append(argTypes, argType)
+		}
 
-		var offset = 1 // first arg is receiver
-		if hasContext {
-			offset++
+		key := method.Name
+		name := cfg.naming(method.Name)
+		if namespace != "" {
+			key = namespace + "." + key
+			name = namespace + "." + name
 		}
 
-		var argTypes []reflect.Type
-		for arg := offset; arg < args; arg++ {
-			argTypes = append(argTypes, method.Type.In(arg))
+		binary := hasResponse && download.IsBinary(responseType)
+		stream := hasResponse && isStream(responseType)
+		async := cfg.async[key]
+		rule := cfg.cached[key]
+		cacheTTL := rule.ttl
+		if len(fileTypes) > 0 || binary || stream {
+			async, cacheTTL = nil, 0
+		}
+		if async != nil {
+			cacheTTL = 0
+		}
+		if cacheTTL == 0 {
+			rule.cache = nil
+		} else if rule.cache.session == nil {
+			checkSession(cfg, key, method, inputs)
 		}
 
 		em := &ExposedMethod{
+			key:          key,
+			name:         name,
+			namespace:    namespace,
+			aliases:      cfg.aliases[key],
+			path:         path,
 			args:         args,
 			receiver:     value,
 			argTypes:     argTypes,
+			fileTypes:    fileTypes,
+			inputs:       inputs,
 			responseType: responseType,
 			hasResponse:  hasResponse,
-			hasContext:   hasContext,
+			binary:       binary,
+			stream:       stream,
 			hasError:     hasError,
-			offset:       offset,
 			method:       method,
+			plan:         newPlan(inputs),
+			async:        async,
+			cache:        rule.cache,
+			cacheTTL:     cacheTTL,
+			caches:       cfg.caches,
+			uploads:      cfg.uploads,
+		}
+		if async == nil && cacheTTL == 0 {
+			em.direct = directCall(cfg, t, method, inputs)
 		}
 
 		handler := em
-		res[method.Name] = handler
+		res[key] = handler
 	}
-	return res
+
+	if cfg.nested {
+		indexFields(res, cfg, namespace, value, path, append(visited, t))
+	}
+}
+
+// indexFields indexes exported non-embedded fields (pointers or interfaces) of struct as nested services.
+// Namespace of field is field name converted by naming strategy or value of `rpc` tag. Tag `rpc:"-"` skips field.
+func indexFields(res map[string]*ExposedMethod, cfg *config, namespace string, value reflect.Value, path []int, visited []reflect.Type) {
+	st := value.Type()
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return
+	}
+	var structValue reflect.Value
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		structValue = value.Elem()
+	} else if value.Kind() == reflect.Struct {
+		structValue = value
+	}
+
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("rpc")
+		if tag == "-" {
+			continue
+		}
+
+		fieldValue := reflect.Zero(f.Type)
+		if structValue.IsValid() {
+			fieldValue = structValue.Field(i)
+		}
+
+		var allowed map[string]bool
+		switch f.Type.Kind() {
+		case reflect.Ptr:
+		case reflect.Interface:
+			if fieldValue.IsNil() {
+				continue // concrete type is unknown
+			}
+			allowed = make(map[string]bool, f.Type.NumMethod())
+			for m := 0; m < f.Type.NumMethod(); m++ {
+				allowed[f.Type.Method(m).Name] = true
+			}
+			fieldValue = fieldValue.Elem()
+		default:
+			continue
+		}
+
+		if containsType(visited, fieldValue.Type()) {
+			continue // recursive type
+		}
+
+		if tag == "" {
+			tag = cfg.naming(f.Name)
+		}
+		if namespace != "" {
+			tag = namespace + "." + tag
+		}
+		fieldPath := append(path[:len(path):len(path)], i)
+		indexObject(res, cfg, tag, fieldValue, fieldPath, allowed, visited)
+	}
+}
+
+func containsType(list []reflect.Type, t reflect.Type) bool {
+	for _, v := range list {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// input describes source of method parameter: payload (zero value), provider or file.
+type input struct {
+	provider inject.Provider // request-scoped parameter
+	file     bool            // file from multipart request
 }
 
 type ExposedMethod struct {
+	key          string // key in index
+	name         string
+	namespace    string
+	aliases      []string
+	path         []int
 	args         int
 	receiver     reflect.Value
 	argTypes     []reflect.Type
+	fileTypes    []reflect.Type
+	inputs       []input // by position in method signature
 	responseType reflect.Type
 	hasResponse  bool
-	hasContext   bool
+	binary       bool
+	stream       bool
 	hasError     bool
-	offset       int
 	method       reflect.Method
+	direct       direct // statically typed call, see Static
+	plan         *plan
+	async        *Jobs             // runner of asynchronous calls, see Async
+	cache        *Cache            // cache of cacheable method, see Cached
+	cacheTTL     time.Duration     // cacheable method if not zero
+	caches       map[string]*Cache // caches of all methods by key, used for invalidation
+	uploads      upload.Limits     // limits of multipart parts, see MaxUploadArgs
+}
+
+// Endpoint name of method according to naming strategy.
+func (em *ExposedMethod) Endpoint() string {
+	return em.name
+}
+
+// Method is Go method of receiver type (receiver is the first argument).
+func (em *ExposedMethod) Method() reflect.Method {
+	return em.method
+}
+
+// Namespace of method or empty string for root methods. See [Registry] and [Nested].
+func (em *ExposedMethod) Namespace() string {
+	return em.namespace
+}
+
+// Aliases are additional endpoint names of method.
+func (em *ExposedMethod) Aliases() []string {
+	return em.aliases
 }
 
 func (em *ExposedMethod) Args() []reflect.Type {
 	return em.argTypes
 }
 
+// Files are types of file arguments (io.Reader, *multipart.FileHeader or [File]), excluded from [ExposedMethod.Args].
+// Methods with files accept only multipart/form-data requests.
+func (em *ExposedMethod) Files() []reflect.Type {
+	return em.fileTypes
+}
+
 func (em *ExposedMethod) HasResponse() bool {
 	return em.hasResponse
 }
@@ -127,70 +373,214 @@ func (em *ExposedMethod) Response() reflect.Type {
 	return em.responseType
 }
 
+// Binary returns true if response is streamed as raw content (io.Reader, io.WriterTo or [Blob]) instead of JSON.
+func (em *ExposedMethod) Binary() bool {
+	return em.binary
+}
+
+// Stream returns true if response is channel of items. Items are pushed over websocket ([WebSocket])
+// or written as newline-delimited JSON over HTTP.
+func (em *ExposedMethod) Stream() bool {
+	return em.stream
+}
+
+// Async returns true if method is called in background and responds by [JobState]. See [Async].
+func (em *ExposedMethod) Async() bool {
+	return em.async != nil
+}
+
+// resolve receiver for method (for nested services) from root object. Returns false if any of fields is nil.
+func (em *ExposedMethod) resolve(root reflect.Value) (reflect.Value, bool) {
+	value := root
+	for _, i := range em.path {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return value, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+		if !value.IsValid() || value.Kind() == reflect.Ptr && value.IsNil() {
+			return value, false
+		}
+	}
+	return value, true
+}
+
 func (em *ExposedMethod) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	em.invoke(em.receiver, writer, request)
 }
 
 func (em *ExposedMethod) invoke(receiver reflect.Value, writer http.ResponseWriter, request *http.Request) {
-	var argValues = make([]reflect.Value, em.offset+len(em.argTypes))
-	argValues[0] = receiver
-	if em.hasContext {
-		argValues[1] = reflect.ValueOf(request.Context())
+	meta := NewResponse()
+	request = request.WithContext(WithResponse(request.Context(), meta))
+	if em.caches != nil {
+		request = em.withCache(request)
 	}
-	dataArgs := argValues[em.offset:]
 
-	var params []json.RawMessage
+	var payload io.Reader = request.Body
+	var files []reflect.Value
+
+	if len(em.fileTypes) > 0 {
+		if !upload.IsMultipart(request) {
+			http.Error(writer, "multipart/form-data expected", http.StatusBadRequest)
+			return
+		}
+		rawArgs, values, cleanup, err := upload.Read(request, em.fileTypes, em.uploads)
+		defer cleanup()
+		if errors.Is(err, upload.ErrTooLarge) {
+			http.Error(writer, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload, files = rawPayload(rawArgs), values
+	}
 
-	if err := json.NewDecoder(request.Body).Decode(&params); err != nil {
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+	if em.async != nil {
+		job, status, err := em.submit(receiver, request, payload)
+		if err != nil {
+			http.Error(writer, err.Error(), status)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusAccepted)
+		writeJSON(writer, job)
 		return
 	}
 
-	if len(params) < len(dataArgs) {
-		http.Error(writer, "not enough arguments, expected "+strconv.Itoa(len(dataArgs)), http.StatusBadRequest)
+	if em.cacheTTL > 0 {
+		em.serveCached(receiver, writer, request, payload, meta)
 		return
 	}
 
-	for arg := range dataArgs {
-		argType := em.argTypes[arg]
-		argValue := reflect.New(argType)
-		if err := json.Unmarshal(params[arg], argValue.Interface()); err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
-			return
+	response, appError, status, err := em.run(receiver, request, payload, files)
+	if err != nil {
+		http.Error(writer, err.Error(), status)
+		return
+	}
+
+	meta.Apply(writer)
+	if appError != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(appError.Error()))
+		return
+	}
+	if em.binary {
+		download.Write(writer, request, response, meta.Status())
+		return
+	}
+	if em.stream {
+		writeStream(writer, request, response, meta.StatusOr(http.StatusOK))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(meta.StatusOr(http.StatusOK))
+	writeJSON(writer, response)
+}
+
+// run method directly (see [Static]) or by reflection with arguments decoded from payload (JSON array).
+// Errors before the call (invalid payload or failed provider) are returned as err with status code,
+// error returned by method is returned as appError.
+func (em *ExposedMethod) run(receiver reflect.Value, request *http.Request, payload io.Reader, files []reflect.Value) (response any, appError error, status int, err error) {
+	if em.direct == nil {
+		args, status, err := em.prepare(receiver, request, payload, files)
+		if err != nil {
+			return nil, nil, status, err
 		}
-		dataArgs[arg] = argValue.Elem()
+		response, appError = em.call(args)
+		return response, appError, 0, nil
 	}
 
-	output := em.method.Func.Call(argValues)
-	responseValues := toAny(output)
+	response, appError = em.direct(receiver, request, newArgs(payload))
+	var argErr *argumentError
+	if errors.As(appError, &argErr) {
+		return nil, nil, http.StatusBadRequest, argErr.err
+	}
+	if appError != nil {
+		if closer, ok := response.(io.Closer); em.binary && ok {
+			_ = closer.Close()
+		}
+		return nil, appError, 0, nil
+	}
+	return response, nil, 0, nil
+}
 
-	var appError error
+// prepare arguments for call: decodes payload, places files and resolves request-scoped parameters.
+// In case of error, status code is 400 for invalid payload or 500 for failed provider.
+// Arguments are taken from pool and returned back by call.
+func (em *ExposedMethod) prepare(receiver reflect.Value, request *http.Request, payload io.Reader, files []reflect.Value) (*[]reflect.Value, int, error) {
+	args := em.plan.get()
+	argValues := *args
+	argValues[0] = receiver
+
+	if err := em.decodeArgs(payload, argValues); err != nil {
+		em.plan.put(args)
+		return nil, http.StatusBadRequest, err
+	}
+	if len(files) < len(em.fileTypes) {
+		em.plan.put(args)
+		return nil, http.StatusBadRequest, errors.New("not enough files, expected " + strconv.Itoa(len(em.fileTypes)))
+	}
+	for i, pos := range em.plan.files {
+		argValues[pos] = files[i]
+	}
 
+	// resolve request-scoped parameters only for valid payload
+	for _, pos := range em.plan.providers {
+		value, err := em.inputs[pos].provider(request)
+		if err != nil {
+			em.plan.put(args)
+			return nil, http.StatusInternalServerError, err
+		}
+		argValues[pos] = value
+	}
+	return args, 0, nil
+}
+
+// decodeArgs decodes payload (JSON array) directly into arguments without intermediate representation, see [Args].
+func (em *ExposedMethod) decodeArgs(payload io.Reader, argValues []reflect.Value) error {
+	args := newArgs(payload)
+	for i, argType := range em.argTypes {
+		value := reflect.New(argType)
+		args.Decode(value.Interface())
+		argValues[em.plan.payload[i]] = value.Elem()
+	}
+	return args.done()
+}
+
+// call method with prepared arguments, which are returned to pool. Binary content is closed in case of error.
+func (em *ExposedMethod) call(args *[]reflect.Value) (any, error) {
+	output := em.method.Func.Call(*args)
+	em.plan.put(args)
+
+	var appError error
 	if em.hasError {
-		if v := responseValues[len(responseValues)-1]; v != nil {
-			appError = v.(error)
+		if v := output[len(output)-1]; v.Kind() != reflect.Interface || !v.IsNil() {
+			appError = v.Interface().(error)
 		}
-		responseValues = responseValues[:len(responseValues)-1]
 	}
 
 	var response any
 	if em.hasResponse {
-		response = responseValues[0]
+		response = output[0].Interface()
 	}
 
 	if appError != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		_, _ = writer.Write([]byte(appError.Error()))
-		return
+		if closer, ok := response.(io.Closer); em.binary && ok {
+			_ = closer.Close()
+		}
+		return nil, appError
 	}
-	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusOK)
-	var encoder = json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	_ = encoder.Encode(response) // too late to do anything
+	return response, nil
 }
 
-// Router creates mux handler which exposes all indexed method with name as path, in lower case,
+// Router creates mux handler which exposes all indexed method with endpoint name (and aliases) as path,
 // and only for POST method.
 //
 //		http.Handle("/api/", http.StripPrefix("/api", Router(...)))
@@ -198,8 +588,11 @@ func (em *ExposedMethod) invoke(receiver reflect.Value, writer http.ResponseWrit
 //	  	MyFoo(..) -> POST /myfoo
 func Router(index map[string]*ExposedMethod) http.Handler {
 	mux := http.NewServeMux()
-	for name, handler := range index {
-		mux.Handle("/"+strings.ToLower(name), handler)
+	for _, handler := range index {
+		mux.Handle("/"+handler.Endpoint(), handler)
+		for _, alias := range handler.Aliases() {
+			mux.Handle("/"+alias, handler)
+		}
 	}
 
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
@@ -223,27 +616,42 @@ func Router(index map[string]*ExposedMethod) http.Handler {
 //		var server Server
 //		handler := Builder(server.newAPI)
 //
+// # Files
+//
+// Arguments of type io.Reader, *multipart.FileHeader or [File] are filled from multipart/form-data request:
+// part "args" contains JSON array of other arguments, parts "file0", "file1", ... contain files in order of arguments.
+// Content is streamed: "args" part must be first and files must follow in order. Only the last file is streamed
+// directly from request, others are stored to temporary files. If any of arguments is *multipart.FileHeader,
+// the whole form is parsed by [http.Request.ParseMultipartForm]. Size of "args" part and of temporary files
+// is limited by [MaxUploadArgs] and [MaxUploadSpool], larger parts cause 413 Request Entity Too Large.
+//
+// # Downloads
+//
+// Results implementing io.Reader or io.WriterTo (for example io.ReadCloser, *os.File or [Blob]) are streamed as raw
+// content instead of JSON. [Blob] sets Content-Type and Content-Disposition. Seekable content (io.ReadSeeker) supports
+// Range and conditional requests, unless custom status is set. Content is closed after writing if it implements io.Closer.
+//
 // # Status codes
 //
 // - 400 Bad Request in case payload can not be unmarshalled to arguments or number of arguments not enough.
-// - 404 Not Found in case method is not known (case-insensitive).
+// - 413 Request Entity Too Large in case part of multipart request exceeds limit, see [MaxUploadArgs]
+// - 404 Not Found in case method is not known (exact endpoint name first, then case-insensitive).
 // - 500 Internal Server Error in case method returned an error or factory returned error. Response payload will be error message (plain text)
 // - 200 OK in case everything fine
-func Builder[T any](factory func(r *http.Request) (T, error)) http.Handler {
+//
+// Cache of cacheable methods ([Cached]) must have session, otherwise Builder panics: responses would be shared
+// between receivers.
+func Builder[T any](factory func(r *http.Request) (T, error), options ...Option) http.Handler {
 	var t T
-	handlers := Index(t)
-	var caseHandlers = make(map[string]*ExposedMethod, len(handlers))
-	for name, handler := range handlers {
-		caseHandlers[strings.ToLower(name)] = handler
-	}
+	index := Index(t, append(options[:len(options):len(options)], perRequest)...)
+	handlers := newLookup(index)
 
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		if request.Method != http.MethodPost {
 			writer.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		method := strings.ToLower(strings.TrimPrefix(request.URL.Path, "/"))
-		handler, ok := caseHandlers[method]
+		handler, ok := handlers.find(strings.TrimPrefix(request.URL.Path, "/"))
 		if !ok {
 			writer.WriteHeader(http.StatusNotFound)
 			return
@@ -256,21 +664,149 @@ func Builder[T any](factory func(r *http.Request) (T, error)) http.Handler {
 			return
 		}
 
-		receiver := reflect.ValueOf(value)
+		receiver, ok := handler.resolve(reflect.ValueOf(value))
+		if !ok {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte("service " + handler.Namespace() + " is not initialized"))
+			return
+		}
 		handler.invoke(receiver, writer, request)
 	})
 }
 
+// lookup finds method by endpoint name or alias: exact name first, then case-insensitive.
+type lookup struct {
+	exact  map[string]*ExposedMethod
+	folded map[string]*ExposedMethod
+}
+
+func newLookup(index map[string]*ExposedMethod) *lookup {
+	var l = &lookup{
+		exact:  make(map[string]*ExposedMethod, len(index)),
+		folded: make(map[string]*ExposedMethod, len(index)),
+	}
+	for _, handler := range index {
+		for _, name := range append([]string{handler.Endpoint()}, handler.Aliases()...) {
+			l.exact[name] = handler
+			l.folded[strings.ToLower(name)] = handler
+		}
+	}
+	return l
+}
+
+func (l *lookup) find(name string) (*ExposedMethod, bool) {
+	if handler, ok := l.exact[name]; ok {
+		return handler, true
+	}
+	handler, ok := l.folded[strings.ToLower(name)]
+	return handler, ok
+}
+
 // New exposes matched methods of object as HTTP endpoints.
-// It's shorthand for Router(Index(object)).
-func New(object interface{}) http.Handler {
-	return Router(Index(object))
+// It's shorthand for Router(Index(object, options...)).
+func New(object interface{}, options ...Option) http.Handler {
+	return Router(Index(object, options...))
+}
+
+// Option configures indexing of methods.
+type Option func(cfg *config)
+
+type config struct {
+	naming     naming.Strategy
+	aliases    map[string][]string
+	nested     bool
+	providers  inject.Providers
+	custom     map[reflect.Type]bool // types registered by Inject
+	static     map[reflect.Type]map[string]direct
+	async      map[string]*Jobs     // by method key
+	cached     map[string]cacheRule // by method key
+	caches     map[string]*Cache    // by method key, built from cached
+	origins    []string             // allowed origins of websocket, see AllowOrigins
+	maxCalls   int                  // in-flight calls per websocket connection, see MaxCalls
+	perRequest bool                 // receiver is created per request, see Builder
+	uploads    upload.Limits        // see MaxUploadArgs and MaxUploadSpool
 }
 
-func toAny(values []reflect.Value) []any {
-	var ans = make([]any, len(values))
-	for i, v := range values {
-		ans[i] = v.Interface()
+func newConfig(options []Option) *config {
+	cfg := &config{
+		naming:    naming.Lower,
+		aliases:   make(map[string][]string),
+		providers: inject.New(),
+		custom:    make(map[reflect.Type]bool),
+		static:    make(map[reflect.Type]map[string]direct),
+		async:     make(map[string]*Jobs),
+		cached:    make(map[string]cacheRule),
+		maxCalls:  DefaultMaxCalls,
+		uploads:   upload.Limits{Args: DefaultMaxUploadArgs},
+	}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	if len(cfg.cached) > 0 {
+		cfg.caches = make(map[string]*Cache, len(cfg.cached))
+		for method, rule := range cfg.cached {
+			cfg.caches[method] = rule.cache
+		}
+	}
+	return cfg
+}
+
+// perRequest marks index of receiver created per request.
+func perRequest(cfg *config) {
+	cfg.perRequest = true
+}
+
+// Naming sets strategy which converts method name to endpoint name. Default is [naming.Lower].
+func Naming(strategy naming.Strategy) Option {
+	return func(cfg *config) {
+		cfg.naming = strategy
+	}
+}
+
+// Alias exposes method (by key in index: Go name or namespace.Name for namespaced methods)
+// under additional endpoint names (used as-is). Useful to keep old endpoint after renaming method.
+// Indexing panics if method is not exposed or alias collides with endpoint of another method.
+//
+//	Alias("CreateUser", "newuser") // CreateUser(...) -> POST /createuser and POST /newuser
+func Alias(method string, aliases ...string) Option {
+	return func(cfg *config) {
+		cfg.aliases[method] = append(cfg.aliases[method], aliases...)
+	}
+}
+
+// Nested enables discovery of services in exported struct fields (pointers or interfaces).
+// Methods of such fields are exposed under namespace, which is field name converted by naming strategy
+// or value of `rpc` tag. Fields can be nested recursively. Tag `rpc:"-"` excludes field.
+//
+//	type API struct {
+//		Users   *UserService                // UserService.Create(...) -> POST /users.create
+//		Billing BillingService `rpc:"bill"` // interface, BillingService.Invoice(...) -> POST /bill.invoice
+//		DB      *sql.DB        `rpc:"-"`    // ignored
+//	}
+//
+// Only methods declared in interface are exposed for interface fields. Nil interface fields are ignored
+// since the concrete type is unknown.
+func Nested() Option {
+	return func(cfg *config) {
+		cfg.nested = true
+	}
+}
+
+// Inject registers provider of request-scoped parameter of type T. Parameters of such type (at any position)
+// are resolved by provider on each request and excluded from payload (and therefore from schema and clients).
+// Provider error causes 500 Internal Server Error.
+//
+// By default, context.Context (request context), *http.Request and http.Header (request headers) are injected.
+//
+//	func (srv *Server) Whoami(ctx context.Context, user *Principal) string
+//
+//	handler := rpc.New(&srv, rpc.Inject(func(r *http.Request) (*Principal, error) {
+//		return authenticate(r.Header.Get("Authorization"))
+//	}))
+func Inject[T any](provider func(r *http.Request) (T, error)) Option {
+	t, p := inject.Typed(provider)
+	return func(cfg *config) {
+		cfg.providers[t] = p
+		cfg.custom[t] = true
 	}
-	return ans
 }
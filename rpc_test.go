@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/naming"
 )
 
 type SomeObj struct {
@@ -74,6 +75,11 @@ func (api *api) Foo5() (int, error) {
 	return 0, nil
 }
 
+func (api *api) GetTotal() int {
+	api.reached = "GetTotal"
+	return 1
+}
+
 func (api *api) Skipped() (int, int) {
 	return 0, 0
 }
@@ -297,6 +303,141 @@ func TestRouter(t *testing.T) {
 	})
 }
 
+func TestNaming(t *testing.T) {
+	r := &api{t: t}
+	router := rpc.New(r, rpc.Naming(naming.Snake), rpc.Alias("Calc", "sum", "add"))
+
+	for _, path := range []string{"/calc", "/sum", "/add"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString("[1, 2]"))
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Error(path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/get_total", bytes.NewBufferString("[]"))
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Error(rec.Code, rec.Body.String())
+	}
+	if r.reached != "GetTotal" {
+		t.Error("not reached method")
+	}
+
+	index := rpc.Index(r, rpc.Naming(naming.Kebab))
+	if name := index["GetTotal"].Endpoint(); name != "get-total" {
+		t.Error(name)
+	}
+}
+
+type collidingAPI struct{}
+
+func (c *collidingAPI) GetUser() string { return "user" }
+func (c *collidingAPI) Getuser() string { return "legacy" }
+func (c *collidingAPI) List() []string  { return nil }
+
+// panicMessage returns message of panic caused by fn or empty string.
+func panicMessage(fn func()) (message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message = fmt.Sprint(r)
+		}
+	}()
+	fn()
+	return ""
+}
+
+func TestNaming_conflicts(t *testing.T) {
+	cases := map[string]struct {
+		index    func()
+		messages []string
+	}{
+		"naming": {
+			index:    func() { rpc.Index(&collidingAPI{}) },
+			messages: []string{"getuser", "GetUser", "Getuser"},
+		},
+		"alias": {
+			index:    func() { rpc.New(&collidingAPI{}, rpc.Naming(naming.AsIs), rpc.Alias("List", "GetUser")) },
+			messages: []string{"GetUser", "List"},
+		},
+		"unknown alias": {
+			index:    func() { rpc.Index(&collidingAPI{}, rpc.Naming(naming.AsIs), rpc.Alias("Lst", "all")) },
+			messages: []string{"all", "Lst"},
+		},
+		"unknown alias in registry": {
+			index: func() {
+				rpc.NewRegistry(rpc.Naming(naming.AsIs)).Add("users", &collidingAPI{}, rpc.Alias("users.Lst", "all")).Index()
+			},
+			messages: []string{"all", "users.Lst"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			message := panicMessage(c.index)
+			if message == "" {
+				t.Fatal("should panic")
+			}
+			for _, part := range c.messages {
+				if !strings.Contains(message, part) {
+					t.Errorf("message %q should contain %q", message, part)
+				}
+			}
+		})
+	}
+
+	// registry-level aliases may refer to methods of any service
+	rpc.NewRegistry(rpc.Naming(naming.AsIs), rpc.Alias("users.List", "all")).
+		Add("users", &collidingAPI{}, rpc.Alias("users.Getuser", "legacy")).
+		Add("billing", &billingService{}).
+		Index()
+}
+
+type principal struct {
+	Name string
+}
+
+type injectAPI struct{}
+
+func (ia *injectAPI) Whoami(ctx context.Context, user *principal, prefix string, headers http.Header, r *http.Request) string {
+	return prefix + user.Name + headers.Get("X-Suffix") + r.URL.Path
+}
+
+func TestInject(t *testing.T) {
+	router := rpc.New(&injectAPI{}, rpc.Inject(func(r *http.Request) (*principal, error) {
+		user := r.Header.Get("X-User")
+		if user == "" {
+			return nil, errors.New("unauthorized")
+		}
+		return &principal{Name: user}, nil
+	}))
+
+	index := rpc.Index(&injectAPI{}, rpc.Inject(func(r *http.Request) (*principal, error) { return nil, nil }))
+	if args := index["Whoami"].Args(); len(args) != 1 {
+		t.Error("injected parameters should be excluded from args", args)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/whoami", bytes.NewBufferString(`["hello, "]`))
+	req.Header.Set("X-User", "reddec")
+	req.Header.Set("X-Suffix", "!")
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	if strings.TrimSpace(rec.Body.String()) != `"hello, reddec!/whoami"` {
+		t.Error(rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/whoami", bytes.NewBufferString(`["hello, "]`))
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("provider error should be 500", rec.Code)
+	}
+}
+
 // semi-realistic example to check concept
 
 type userSession struct {
@@ -354,6 +495,19 @@ func TestBuilder(t *testing.T) {
 			t.Error(rec.Body.String())
 		}
 	})
+	t.Run("naming strategy and aliases", func(t *testing.T) {
+		var srv server
+		handler := rpc.Builder(srv.newSession, rpc.Naming(naming.AsIs), rpc.Alias("Greet", "hello"))
+
+		for _, path := range []string{"/Greet", "/greet", "/hello"} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString("[]"))
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Error(path, rec.Code)
+			}
+		}
+	})
 	t.Run("unknown method should return 404", func(t *testing.T) {
 		var srv server
 		handler := rpc.Builder(srv.newSession)
@@ -394,3 +548,36 @@ func TestBuilder(t *testing.T) {
 		}
 	})
 }
+
+func TestPayload(t *testing.T) {
+	cases := []struct {
+		name    string
+		method  string
+		payload string
+		status  int
+		body    string
+	}{
+		{"extra arguments ignored", "Calc", `[1, 2, {"x": [3]}]`, http.StatusOK, "3\n"},
+		{"null without arguments", "Fail", `null`, http.StatusInternalServerError, "fail"},
+		{"null with arguments", "Calc", `null`, http.StatusBadRequest, "not enough arguments, expected 2\n"},
+		{"not array", "Calc", `{"a": 1}`, http.StatusBadRequest, "json: cannot unmarshal object into Go value of type []"},
+		{"malformed extra argument", "Calc", `[1, 2, x]`, http.StatusBadRequest, ""},
+		{"not enough before type error", "Calc", `["1"]`, http.StatusBadRequest, "not enough arguments, expected 2\n"},
+		{"first type error", "Calc", `["1", "2"]`, http.StatusBadRequest, "json: cannot unmarshal string into Go value of type int\n"},
+		{"empty", "Calc", ``, http.StatusBadRequest, "EOF\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &api{t: t}
+			handler := rpc.Index(r)[c.method]
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.payload)))
+			if rec.Code != c.status {
+				t.Fatal(rec.Code, rec.Body.String())
+			}
+			if !strings.HasPrefix(rec.Body.String(), c.body) {
+				t.Fatalf("%q", rec.Body.String())
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ package schema
 
 import (
 	"encoding/json"
-	"math"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/internal/security"
+	"github.com/reddec/rpc/jsonschema"
 )
 
 // Schema represents OpenAPI definition of endpoints.
@@ -20,59 +23,94 @@ type Schema struct {
 		Title   string `json:"title" yaml:"title"`
 		Version string `json:"version" yaml:"version"`
 	} `json:"info" yaml:"info"`
-	Paths      map[string]Path `json:"paths,omitempty" yaml:"paths,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      map[string]Path       `json:"paths,omitempty" yaml:"paths,omitempty"`
+	Security   []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"` // any of schemes, see [Security]
 	Components struct {
-		Schemas map[string]*Type `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+		Schemas         map[string]*Type           `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
 	} `json:"components,omitempty" yaml:"components,omitempty"`
 }
 
+// SecurityScheme describes authentication (type: http or apiKey), see [Security].
+// The same type is used by package jrpc.
+type SecurityScheme = security.Scheme
+
+// BearerAuth is token in Authorization header. Format (optional) is a hint for clients, ex: JWT.
+func BearerAuth(format string) *SecurityScheme {
+	return security.Bearer(format)
+}
+
+// BasicAuth is user and password in Authorization header.
+func BasicAuth() *SecurityScheme {
+	return security.Basic()
+}
+
+// HeaderAuth is API key in header.
+func HeaderAuth(header string) *SecurityScheme {
+	return security.Header(header)
+}
+
+// CookieAuth is API key in cookie.
+func CookieAuth(cookie string) *SecurityScheme {
+	return security.Cookie(cookie)
+}
+
 // Server represents reference to API server.
 type Server struct {
 	URL string `json:"url,omitempty" yaml:"url,omitempty"`
 }
 
+// Tag groups endpoints. Each namespace (see [rpc.Registry]) is exposed as tag.
+type Tag struct {
+	Name string `json:"name" yaml:"name"`
+}
+
 type Path struct {
 	Post Endpoint `json:"post" yaml:"post"`
 }
 
 type Endpoint struct {
-	Summary     string  `json:"summary,omitempty" yaml:"summary,omitempty"`
-	OperationID string  `json:"operationId" yaml:"operationId"`
-	RequestBody Payload `json:"requestBody" yaml:"requestBody"`
+	Summary     string                `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationID string                `json:"operationId" yaml:"operationId"`
+	Tags        []string              `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"` // overrides global security
+	RequestBody Payload               `json:"requestBody" yaml:"requestBody"`
 	Responses   struct {
 		OK            Payload  `json:"200" yaml:"200"`
+		Accepted      *Payload `json:"202,omitempty" yaml:"202,omitempty"` // asynchronous methods, see [rpc.Async]
 		BadRequest    *Payload `json:"400" yaml:"400"`
 		InternalError *Payload `json:"500" yaml:"500"`
 	} `json:"responses" yaml:"responses"`
 }
 
 type ContentType struct {
-	Schema *Type `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Schema   *Type               `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Encoding map[string]Encoding `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// Encoding of multipart property.
+type Encoding struct {
+	ContentType string `json:"contentType,omitempty" yaml:"contentType,omitempty"`
 }
 
 type Payload struct {
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 	Content     struct {
-		JSON  *ContentType `json:"application/json,omitempty" yaml:"application/json,omitempty"`
-		Plain *ContentType `json:"text/plain,omitempty" yaml:"text/plain,omitempty"`
+		JSON      *ContentType `json:"application/json,omitempty" yaml:"application/json,omitempty"`
+		Plain     *ContentType `json:"text/plain,omitempty" yaml:"text/plain,omitempty"`
+		Multipart *ContentType `json:"multipart/form-data,omitempty" yaml:"multipart/form-data,omitempty"`
+		Binary    *ContentType `json:"application/octet-stream,omitempty" yaml:"application/octet-stream,omitempty"`
+		Stream    *ContentType `json:"application/x-ndjson,omitempty" yaml:"application/x-ndjson,omitempty"`
 	} `json:"content,omitempty" yaml:"content,omitempty"`
 }
 
-type Type struct {
-	Type        string           `json:"type,omitempty" yaml:"type,omitempty"`
-	Format      string           `json:"format,omitempty" yaml:"format,omitempty"`
-	Ref         string           `json:"$ref,omitempty" yaml:"$ref,omitempty"`
-	Items       *Type            `json:"items,omitempty" yaml:"items,omitempty"`
-	Properties  map[string]*Type `json:"properties,omitempty" yaml:"properties,omitempty"`
-	Required    []string         `json:"required,omitempty" yaml:"required,omitempty"`
-	Minimum     *int64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
-	Maximum     int64            `json:"maximum,omitempty" yaml:"maximum,omitempty"`
-	PrefixItems []*Type          `json:"prefixItems,omitempty" yaml:"prefixItems,omitempty"`
-	MinItems    int              `json:"minItems,omitempty" yaml:"minItems,omitempty"`
-	MaxItems    int              `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
-	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
-	Name        string           `json:"-" yaml:"-"`
-}
+// Type is JSON Schema of value, see [jsonschema.Type].
+type Type = jsonschema.Type
+
+// Discriminator of polymorphic type (see package poly).
+type Discriminator = jsonschema.Discriminator
 
 // Handler exposes OpenAPI 3.1 cached pre-generated spec. See [OpenAPI].
 // This is just an alias to Expose(OpenAPI()).
@@ -99,201 +137,100 @@ type Option func(builder *schemaBuilder)
 // OpenAPI generates Open-API 3.1 schema based on pre-indexed server object (see [rpc.Index]).
 // It's recommend to cache result.
 func OpenAPI(index map[string]*rpc.ExposedMethod, options ...Option) *Schema {
-	var zero = new(int64)
-	sb := schemaBuilder{
-		components: make(map[schemaRef]*Type),
-		names:      make(map[string]int),
-		hooks: map[schemaRef]*Type{
-			{pkg: "time", name: "Time"}:                             {Type: "string", Format: "date-time"},
-			{pkg: "time", name: "Duration"}:                         {Type: "string", Description: "duration with unit prefix"},
-			{pkg: "github.com/shopspring/decimal", name: "Decimal"}: {Type: "string", Description: "precise representation of decimal value"},
-		},
-		defaults: schemaDefaults{
-			// defaults avoids creating same type,
-			// reduces memory allocation, and allows customization
-			Int:   &Type{Type: "integer"},
-			Int64: &Type{Type: "integer", Format: "int64"},
-			Int32: &Type{Type: "integer", Format: "int32"},
-			Int16: &Type{Type: "integer", Maximum: math.MaxInt16},
-			Int8:  &Type{Type: "integer", Maximum: math.MaxInt8},
-
-			UInt:   &Type{Type: "integer", Minimum: zero},
-			UInt64: &Type{Type: "integer", Format: "int64", Minimum: zero},
-			UInt32: &Type{Type: "integer", Format: "int32", Minimum: zero},
-			UInt16: &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint16},
-			UInt8:  &Type{Type: "integer", Minimum: zero, Maximum: math.MaxUint8},
-
-			String:  &Type{Type: "string"},
-			Bool:    &Type{Type: "boolean"},
-			Float32: &Type{Type: "number", Format: "float"},
-			Float64: &Type{Type: "number", Format: "double"},
-
-			Base64: &Type{Type: "string", Format: "byte"},
-			Any:    &Type{},
-		},
-	}
-
+	var sb schemaBuilder
 	for _, opt := range options {
 		opt(&sb)
 	}
+	sb.types = jsonschema.New("#/components/schemas/", sb.options...)
 	schema := sb.build(index)
 	return schema
 }
 
-type schemaRef struct {
-	pkg  string
-	name string
+type schemaBuilder struct {
+	title    string
+	version  string
+	urls     []string
+	options  []jsonschema.Option
+	types    *jsonschema.Builder
+	schemes  map[string]*SecurityScheme
+	security security.Requirements
 }
 
-func refOf(t reflect.Type) schemaRef {
-	return schemaRef{
-		pkg:  t.PkgPath(),
-		name: t.Name(),
+// summarize splits description to summary (first line) and full description (if it has more than one line).
+func summarize(description string) (summary string, full string) {
+	summary, _, multiline := strings.Cut(description, "\n")
+	if multiline {
+		full = description
 	}
+	return summary, full
 }
 
-type schemaDefaults struct {
-	Int   *Type
-	Int64 *Type
-	Int32 *Type
-	Int16 *Type
-	Int8  *Type
-
-	UInt   *Type
-	UInt64 *Type
-	UInt32 *Type
-	UInt16 *Type
-	UInt8  *Type
-
-	String  *Type
-	Bool    *Type
-	Float32 *Type
-	Float64 *Type
-
-	Base64 *Type
-	Any    *Type
-}
-
-type schemaBuilder struct {
-	title      string
-	version    string
-	components map[schemaRef]*Type
-	names      map[string]int
-	hooks      map[schemaRef]*Type
-	defaults   schemaDefaults
-	urls       []string
-}
-
-func (sb *schemaBuilder) walk(t reflect.Type) *Type {
-	if mock, exists := sb.hooks[refOf(t)]; exists {
-		return mock
+// methodDoc is description of method by key in index or by receiver type and Go name
+// (ex: github.com/foo/bar.Server.Create), which doesn't depend on namespace and naming.
+func (sb *schemaBuilder) methodDoc(key string, info *rpc.ExposedMethod) string {
+	if doc := sb.types.Doc(key); doc != "" {
+		return doc
 	}
-	switch t.Kind() {
-	case reflect.Ptr:
-		return sb.walk(t.Elem())
-	case reflect.Int:
-		return sb.defaults.Int
-	case reflect.Int64:
-		return sb.defaults.Int64
-	case reflect.Int32:
-		return sb.defaults.Int32
-	case reflect.Int16:
-		return sb.defaults.Int16
-	case reflect.Int8:
-		return sb.defaults.Int8
-
-	case reflect.Uint:
-		return sb.defaults.UInt
-	case reflect.Uint64:
-		return sb.defaults.UInt64
-	case reflect.Uint32:
-		return sb.defaults.UInt32
-	case reflect.Uint16:
-		return sb.defaults.UInt16
-	case reflect.Uint8:
-		return sb.defaults.UInt8
-	case reflect.String:
-		return sb.defaults.String
-	case reflect.Bool:
-		return sb.defaults.Bool
-	case reflect.Float32:
-		return sb.defaults.Float32
-	case reflect.Float64:
-		return sb.defaults.Float64
-	case reflect.Slice:
-		if t.Elem().Kind() == reflect.Uint8 {
-			// base64
-			return sb.defaults.Base64
-		}
-		return &Type{Type: "array", Items: sb.walk(t.Elem())}
-	case reflect.Array:
-		return &Type{Type: "array", Items: sb.walk(t.Elem()), MinItems: t.Len(), MaxItems: t.Len()}
-	case reflect.Struct:
-		if t.Name() == "" { //anonymous, we need to embed
-			return sb.walkStruct(t)
-		}
-		info := sb.walkStruct(t)
-		return &Type{Ref: "#/components/schemas/" + info.Name}
-	default:
-		return sb.defaults.Any
+	receiver := info.Method().Type.In(0)
+	if receiver.Kind() == reflect.Ptr {
+		receiver = receiver.Elem()
+	}
+	if receiver.Name() == "" {
+		return ""
 	}
+	return sb.types.Doc(receiver.PkgPath() + "." + receiver.Name() + "." + info.Method().Name)
 }
 
-func (sb *schemaBuilder) walkStruct(t reflect.Type) *Type {
-	ref := refOf(t)
-	var anonymous = ref.name == ""
-
-	if !anonymous {
-		if parsed, ok := sb.components[ref]; ok {
-			return parsed
-		}
+func (sb *schemaBuilder) walkMethodArgs(method *rpc.ExposedMethod) *Type {
+	var res = &Type{
+		Type:     "array",
+		MinItems: len(method.Args()),
+		MaxItems: len(method.Args()),
+		Items:    sb.types.Defaults.Any,
 	}
+	for _, arg := range method.Args() {
+		res.PrefixItems = append(res.PrefixItems, sb.types.Schema(arg))
+	}
+	return res
+}
 
-	cardinality := sb.names[t.Name()]
-	sb.names[t.Name()] += 1
-
-	n := t.NumField()
+// walkMultipart describes multipart request: part "args" with JSON arguments and binary parts for files.
+func (sb *schemaBuilder) walkMultipart(method *rpc.ExposedMethod) *ContentType {
 	var res = &Type{
 		Type:       "object",
-		Properties: make(map[string]*Type, n),
-	}
-	if cardinality == 0 {
-		res.Name = t.Name()
-	} else {
-		res.Name = t.Name() + strconv.Itoa(n)
+		Properties: map[string]*Type{},
 	}
-
-	if !anonymous {
-		// enable self reference
-		sb.components[ref] = res
+	var content = &ContentType{Schema: res}
+	if len(method.Args()) > 0 {
+		res.Properties["args"] = sb.walkMethodArgs(method)
+		res.Required = append(res.Required, "args")
+		content.Encoding = map[string]Encoding{"args": {ContentType: "application/json"}}
 	}
-
-	for i := 0; i < n; i++ {
-		f := t.Field(i)
-		if !f.IsExported() {
-			continue
-		}
-		value := f.Tag.Get("json")
-		if value == "-" {
-			continue
-		}
-		if value == "" {
-			value = f.Name
-		}
-		res.Properties[value] = sb.walk(f.Type)
+	for i := range method.Files() {
+		name := "file" + strconv.Itoa(i)
+		res.Properties[name] = sb.types.Defaults.Binary
+		res.Required = append(res.Required, name)
 	}
-	return res
+	return content
 }
 
-func (sb *schemaBuilder) walkMethodArgs(method *rpc.ExposedMethod) *Type {
-	var res = &Type{
-		Type:     "array",
-		MinItems: len(method.Args()),
-		MaxItems: len(method.Args()),
-		Items:    sb.defaults.Any,
+// walkJob describes job (see [rpc.JobState]) of asynchronous method with typed result.
+func (sb *schemaBuilder) walkJob(method *rpc.ExposedMethod) *Type {
+	var timestamp = sb.types.Schema(reflect.TypeOf(time.Time{}))
+	res := &Type{
+		Type: "object",
+		Properties: map[string]*Type{
+			"id":      sb.types.Defaults.String,
+			"method":  sb.types.Defaults.String,
+			"status":  {Type: "string", Enum: []any{"running", "done", "failed", "canceled"}},
+			"error":   sb.types.Defaults.String,
+			"created": timestamp,
+			"updated": timestamp,
+		},
+		Required: []string{"id", "method", "status", "created", "updated"},
 	}
-	for _, arg := range method.Args() {
-		res.PrefixItems = append(res.PrefixItems, sb.walk(arg))
+	if method.HasResponse() {
+		res.Properties["result"] = sb.types.Schema(method.Response())
 	}
 	return res
 }
@@ -310,7 +247,7 @@ func (sb *schemaBuilder) build(index map[string]*rpc.ExposedMethod) *Schema {
 	}
 
 	// we are preparing all response types since they all the same for all endpoints.
-	var errorType = &ContentType{Schema: sb.defaults.String}
+	var errorType = &ContentType{Schema: sb.types.Defaults.String}
 
 	var badRequest = &Payload{
 		Description: "Payload can not be unmarshalled to arguments or number of arguments not enough, returns error message (plain text)",
@@ -322,33 +259,55 @@ func (sb *schemaBuilder) build(index map[string]*rpc.ExposedMethod) *Schema {
 	}
 	internalError.Content.Plain = errorType
 
+	var tags = make(map[string]bool)
 	for method, info := range index {
 		var path Path
 
 		path.Post.OperationID = method
-		path.Post.RequestBody.Content.JSON = new(ContentType)
-		path.Post.RequestBody.Content.JSON.Schema = sb.walkMethodArgs(info)
-		path.Post.Responses.OK.Description = "Success"
-
-		path.Post.Responses.OK.Content.JSON = new(ContentType)
-		if !info.HasResponse() {
-			path.Post.Responses.OK.Content.JSON.Schema = sb.defaults.Any
+		path.Post.Security = sb.security.Method(method)
+		path.Post.Summary, path.Post.Description = summarize(sb.methodDoc(method, info))
+		if ns := info.Namespace(); ns != "" {
+			path.Post.Tags = []string{ns}
+			tags[ns] = true
+		}
+		if len(info.Files()) > 0 {
+			path.Post.RequestBody.Content.Multipart = sb.walkMultipart(info)
 		} else {
-			path.Post.Responses.OK.Content.JSON.Schema = sb.walk(info.Response())
+			path.Post.RequestBody.Content.JSON = new(ContentType)
+			path.Post.RequestBody.Content.JSON.Schema = sb.walkMethodArgs(info)
+		}
+		var ok = Payload{Description: "Success"}
+		switch {
+		case info.Async():
+			ok.Description = "Not used, asynchronous method replies with 202 Accepted"
+			path.Post.Responses.Accepted = &Payload{Description: "Job started, result can be obtained by job ID"}
+			path.Post.Responses.Accepted.Content.JSON = &ContentType{Schema: sb.walkJob(info)}
+		case info.Binary():
+			ok.Content.Binary = &ContentType{Schema: sb.types.Defaults.Binary}
+		case info.Stream():
+			ok.Content.Stream = &ContentType{Schema: sb.types.Schema(info.Response().Elem())}
+		case info.HasResponse():
+			ok.Content.JSON = &ContentType{Schema: sb.types.Schema(info.Response())}
+		default:
+			ok.Content.JSON = &ContentType{Schema: sb.types.Defaults.Any}
 		}
+		path.Post.Responses.OK = ok
 
 		path.Post.Responses.BadRequest = badRequest
 		path.Post.Responses.InternalError = internalError
-		schema.Paths["/"+strings.ToLower(method)] = path
+		schema.Paths["/"+info.Endpoint()] = path
 	}
 
-	schema.Components.Schemas = map[string]*Type{}
-	for ref, component := range sb.components {
-		if ref.name == "" {
-			continue
-		}
-		schema.Components.Schemas[component.Name] = component
+	for tag := range tags {
+		schema.Tags = append(schema.Tags, Tag{Name: tag})
 	}
+	sort.Slice(schema.Tags, func(i, j int) bool {
+		return schema.Tags[i].Name < schema.Tags[j].Name
+	})
+
+	schema.Components.Schemas = sb.types.Components()
+	schema.Components.SecuritySchemes = sb.schemes
+	schema.Security = sb.security.Global()
 	return &schema
 }
 
@@ -368,12 +327,7 @@ func Version(version string) Option {
 
 // Define specific type as OpenAPI definition.
 func Define(pkg, name string, definition *Type) Option {
-	return func(builder *schemaBuilder) {
-		builder.hooks[schemaRef{
-			pkg:  pkg,
-			name: name,
-		}] = definition
-	}
+	return Types(jsonschema.Define(pkg, name, definition))
 }
 
 // URL for OpenAPI server.
@@ -382,3 +336,66 @@ func URL(urls ...string) Option {
 		builder.urls = urls
 	}
 }
+
+// AllOf describes named embedded structs by allOf composition (reference to embedded type and own properties)
+// instead of flattened properties. Embedded structs with conflicting properties are always flattened.
+func AllOf() Option {
+	return Types(jsonschema.AllOf())
+}
+
+// Describe sets description of method (by key in index). The first line is used as summary.
+func Describe(method string, description string) Option {
+	return Types(jsonschema.Docs(map[string]string{method: description}))
+}
+
+// Docs sets descriptions of methods (by key in index or by package path, receiver type and method name,
+// ex: github.com/foo/bar.Server.Create), types (by package path and name, ex: github.com/foo/bar.User)
+// and fields (ex: github.com/foo/bar.User.Name). Usually table is generated from doc comments by rpc-gen with -docs flag.
+// Field tag `description` has priority over docs.
+//
+//	schema.Handler(rpc.Index(&server), schema.Docs(ServerDocs()))
+func Docs(table map[string]string) Option {
+	return Types(jsonschema.Docs(table))
+}
+
+// Security declares authentication scheme by name. Scheme is required by listed methods (by key in index),
+// or by all methods if list is empty. Several schemes of method (or global) are alternatives.
+//
+//	schema.OpenAPI(index,
+//		schema.Security("token", schema.BearerAuth("JWT")),
+//		schema.Security("session", schema.CookieAuth("session"), "Profile"),
+//		schema.Public("Login"),
+//	)
+func Security(name string, scheme *SecurityScheme, methods ...string) Option {
+	return func(builder *schemaBuilder) {
+		if builder.schemes == nil {
+			builder.schemes = make(map[string]*SecurityScheme)
+		}
+		builder.schemes[name] = scheme
+		builder.security.Require(name, methods...)
+	}
+}
+
+// Public marks methods (by key in index) as available without authentication, regardless of global schemes.
+func Public(methods ...string) Option {
+	return func(builder *schemaBuilder) {
+		builder.security.Public(methods...)
+	}
+}
+
+// Types applies options of schema engine, see package jsonschema.
+func Types(options ...jsonschema.Option) Option {
+	return func(builder *schemaBuilder) {
+		builder.options = append(builder.options, options...)
+	}
+}
+
+// Definer is implemented by types (or pointers to types) with custom schema, see [jsonschema.Definer].
+//
+//	func (Money) JSONSchema() *schema.Type {
+//		return &schema.Type{Type: "string", Description: "amount with currency, ex: 10.5 USD"}
+//	}
+type Definer = jsonschema.Definer
+
+// Enumer is implemented by types (or pointers to types) with fixed set of values, see [jsonschema.Enumer].
+type Enumer = jsonschema.Enumer
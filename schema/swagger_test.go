@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/poly"
 	"github.com/reddec/rpc/schema"
 )
 
@@ -65,3 +71,418 @@ func TestOpenAPI(t *testing.T) {
 	}
 	t.Logf(buf.String())
 }
+
+func TestOpenAPI_namespaces(t *testing.T) {
+	index := rpc.NewRegistry().Add("users", &Server{}).Index()
+	doc := schema.OpenAPI(index)
+	path, ok := doc.Paths["/users.getuser"]
+	if !ok {
+		t.Fatal("namespaced path should exist")
+	}
+	if len(path.Post.Tags) != 1 || path.Post.Tags[0] != "users" {
+		t.Error(path.Post.Tags)
+	}
+	if len(doc.Tags) != 1 || doc.Tags[0].Name != "users" {
+		t.Error(doc.Tags)
+	}
+}
+
+type fileServer struct{}
+
+func (fs *fileServer) Upload(ctx context.Context, name string, content io.Reader) error {
+	return nil
+}
+
+func TestOpenAPI_multipart(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	body := doc.Paths["/upload"].Post.RequestBody.Content
+	if body.JSON != nil || body.Multipart == nil {
+		t.Fatal("multipart body expected")
+	}
+	props := body.Multipart.Schema.Properties
+	if props["file0"].Format != "binary" || props["args"].Type != "array" {
+		t.Error(props)
+	}
+}
+
+func (fs *fileServer) Download(ctx context.Context, name string) (*rpc.Blob, error) {
+	return nil, nil
+}
+
+func TestOpenAPI_binary(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	content := doc.Paths["/download"].Post.Responses.OK.Content
+	if content.JSON != nil || content.Binary == nil || content.Binary.Schema.Format != "binary" {
+		t.Fatal("binary response expected")
+	}
+}
+
+func (fs *fileServer) Watch(ctx context.Context) <-chan *User {
+	return nil
+}
+
+func TestOpenAPI_stream(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	content := doc.Paths["/watch"].Post.Responses.OK.Content
+	if content.JSON != nil || content.Stream == nil || content.Stream.Schema.Ref == "" {
+		t.Fatal("stream of items expected")
+	}
+}
+
+func (fs *fileServer) Export(ctx context.Context, format string) (*User, error) {
+	return nil, nil
+}
+
+func TestOpenAPI_async(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}, rpc.Async(rpc.NewJobs(nil, nil), "Export")))
+	responses := doc.Paths["/export"].Post.Responses
+	if responses.OK.Content.JSON != nil || responses.Accepted == nil {
+		t.Fatal("job in 202 Accepted expected")
+	}
+	job := responses.Accepted.Content.JSON.Schema
+	if job.Properties["id"].Type != "string" || job.Properties["result"].Ref == "" {
+		t.Error(job.Properties)
+	}
+}
+
+type Tagged struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:",omitempty"`
+	Age     int    `json:"age,string"`
+	Note    *int   `json:"note,omitempty,string"`
+	Bad     string `json:"a\"b"`
+	Hidden  string `json:"-"`
+	Dash    string `json:"-,"`
+}
+
+func (fs *fileServer) Tag(tagged Tagged) {}
+
+func TestOpenAPI_tags(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	tagged := doc.Components.Schemas["Tagged"]
+	var names []string
+	for name := range tagged.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "-,Bad,Enabled,age,name,note" {
+		t.Fatal(names)
+	}
+	if tagged.Properties["age"].Type != "string" || tagged.Properties["note"].Type != "string" {
+		t.Error("string option should produce string schema")
+	}
+	if strings.Join(tagged.Required, ",") != "name,age,Bad,-" {
+		t.Error(tagged.Required)
+	}
+}
+
+type Base struct {
+	ID      int64
+	Version int `json:"version"`
+}
+
+type Audit struct {
+	ID      string
+	Version int `json:"version"`
+	Author  string
+}
+
+type Document struct {
+	Base
+	*Audit
+	Title string
+}
+
+type Note struct {
+	Base
+	Text string
+}
+
+func (fs *fileServer) Save(doc Document, note Note) {}
+
+func TestOpenAPI_embedded(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	document := doc.Components.Schemas["Document"]
+	var names []string
+	for name := range document.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// ID and version are conflicting at the same depth
+	if strings.Join(names, ",") != "Author,Title" || document.AllOf != nil {
+		t.Fatal(names)
+	}
+	if _, ok := doc.Components.Schemas["Base"]; ok {
+		t.Error("flattened struct should not be component")
+	}
+
+	doc = schema.OpenAPI(rpc.Index(&fileServer{}), schema.AllOf())
+	note := doc.Components.Schemas["Note"]
+	if len(note.AllOf) != 2 || note.AllOf[0].Ref != "#/components/schemas/Base" || note.AllOf[1].Properties["Text"] == nil {
+		t.Fatalf("%+v", note)
+	}
+	if len(doc.Components.Schemas["Document"].AllOf) != 0 {
+		t.Error("embedded structs with conflicts should be flattened")
+	}
+}
+
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(int(l))), nil
+}
+
+type Settings struct {
+	Labels  map[string]string
+	Limits  map[level][]int
+	Broken  map[[2]int]string
+	Raw     json.RawMessage
+	Timeout time.Duration
+	Created *time.Time
+}
+
+func (fs *fileServer) Configure(settings Settings) {}
+
+func TestOpenAPI_types(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	props := doc.Components.Schemas["Settings"].Properties
+	if props["Labels"].Type != "object" || props["Labels"].Additional.Type != "string" {
+		t.Error("map of strings expected", props["Labels"])
+	}
+	if props["Limits"].Additional.Type != "array" {
+		t.Error("text marshaler keys expected", props["Limits"])
+	}
+	if props["Broken"].Type != "" || props["Raw"].Type != "" || props["Raw"].Format != "" {
+		t.Error("any expected")
+	}
+	if props["Timeout"].Type != "integer" || props["Created"].Format != "date-time" {
+		t.Error("time types expected")
+	}
+}
+
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m *Money) JSONSchema() *schema.Type {
+	return &schema.Type{Type: "string", Description: "amount with currency"}
+}
+
+type Secret struct {
+	value string
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal("***")
+}
+
+type Invoice struct {
+	Total  Money
+	Tax    *Money
+	Secret Secret
+	Level  level
+}
+
+func (fs *fileServer) Bill(invoice Invoice) {}
+
+func TestOpenAPI_definer(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	props := doc.Components.Schemas["Invoice"].Properties
+	if props["Total"].Ref != "#/components/schemas/Money" || props["Tax"].Ref != props["Total"].Ref {
+		t.Error("reference to definition expected", props["Total"])
+	}
+	if money := doc.Components.Schemas["Money"]; money.Type != "string" || money.Properties != nil {
+		t.Error("definition should be used", money)
+	}
+	if props["Secret"].Type != "" || props["Level"].Type != "string" {
+		t.Error("marshalers should not be described by layout")
+	}
+
+	doc = schema.OpenAPI(rpc.Index(&fileServer{}), schema.Define(reflect.TypeOf(Money{}).PkgPath(), "Money", &schema.Type{Type: "integer"}))
+	if props := doc.Components.Schemas["Invoice"].Properties; props["Total"].Type != "integer" {
+		t.Error("registered definition has priority")
+	}
+}
+
+type Status string
+
+func (Status) Enum() []any {
+	return []any{"active", "blocked"}
+}
+
+type Priority int
+
+func (*Priority) Enum() []any {
+	return []any{1, 2, 3}
+}
+
+type Ticket struct {
+	Status   Status
+	Priority *Priority
+}
+
+func (fs *fileServer) Open(ticket Ticket) {}
+
+func TestOpenAPI_enum(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	status := doc.Components.Schemas["Status"]
+	if status.Type != "string" || len(status.Enum) != 2 {
+		t.Error(status)
+	}
+	priority := doc.Components.Schemas["Priority"]
+	if priority.Type != "integer" || len(priority.Enum) != 3 {
+		t.Error(priority)
+	}
+	if doc.Components.Schemas["Ticket"].Properties["Status"].Ref != "#/components/schemas/Status" {
+		t.Error("reference expected")
+	}
+}
+
+type Event interface{ event() }
+
+type Created struct {
+	ID     int
+	Parent poly.Value[Event] `json:",omitempty"`
+}
+
+func (*Created) event() {}
+
+type Deleted struct{ ID int }
+
+func (Deleted) event() {}
+
+func init() {
+	poly.Define[Event]("kind",
+		poly.Variant[*Created]("created"),
+		poly.Variant[Deleted]("deleted"),
+	)
+}
+
+func (fs *fileServer) Publish(events []poly.Value[Event]) poly.Value[Event] {
+	return poly.Value[Event]{}
+}
+
+func (fs *fileServer) Last() Event { return nil }
+
+func TestOpenAPI_poly(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}))
+	event := doc.Components.Schemas["Event"]
+	if event == nil || len(event.OneOf) != 2 || event.Discriminator.PropertyName != "kind" {
+		t.Fatal(event)
+	}
+	if event.OneOf[0].Ref != "#/components/schemas/Event_created" || event.Discriminator.Mapping["deleted"] != "#/components/schemas/Event_deleted" {
+		t.Error(event.OneOf, event.Discriminator.Mapping)
+	}
+	created := doc.Components.Schemas["Event_created"]
+	if len(created.AllOf) != 2 || created.AllOf[0].Ref != "#/components/schemas/Created" || created.AllOf[1].Properties["kind"].Enum[0] != "created" {
+		t.Error(created)
+	}
+	if doc.Components.Schemas["Created"].Properties["Parent"].Ref != "#/components/schemas/Event" {
+		t.Error("self reference expected")
+	}
+	if doc.Paths["/publish"].Post.Responses.OK.Content.JSON.Schema.Ref != "#/components/schemas/Event" {
+		t.Error("interface should be referenced")
+	}
+	if last := doc.Paths["/last"].Post.Responses.OK.Content.JSON.Schema; last.Ref != "" || len(last.OneOf) != 0 {
+		t.Error("plain interface is encoded without discriminator", last)
+	}
+}
+
+type Profile struct {
+	Name    string `description:"login of user" example:"alice"`
+	Age     int    `example:"42"`
+	Email   string
+	Contact Contact
+}
+
+type Contact struct {
+	Phone string
+}
+
+func (fs *fileServer) Register(profile Profile) {}
+
+func TestOpenAPI_docs(t *testing.T) {
+	const pkg = "github.com/reddec/rpc/schema_test"
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}), schema.Docs(map[string]string{
+		"Register":               "Register user.\nProfile is saved as-is.",
+		"Bill":                   "From docs",
+		pkg + ".Profile":         "Profile of user.",
+		pkg + ".Profile.Name":    "ignored, tag has priority",
+		pkg + ".Profile.Email":   "contact email",
+		pkg + ".Profile.Contact": "primary contact",
+		pkg + ".Contact":         "Contact details.",
+	}), schema.Describe("Bill", "Explicit"))
+
+	register := doc.Paths["/register"].Post
+	if register.Summary != "Register user." || register.Description != "Register user.\nProfile is saved as-is." {
+		t.Error(register.Summary, register.Description)
+	}
+	if bill := doc.Paths["/bill"].Post; bill.Summary != "Explicit" || bill.Description != "" {
+		t.Error(bill.Summary, bill.Description)
+	}
+
+	profile := doc.Components.Schemas["Profile"]
+	if profile.Description != "Profile of user." || doc.Components.Schemas["Contact"].Description != "Contact details." {
+		t.Error(profile.Description)
+	}
+	name := profile.Properties["Name"]
+	if name.Type != "string" || name.Description != "login of user" || name.Examples[0] != "alice" {
+		t.Error(name)
+	}
+	if age := profile.Properties["Age"]; age.Type != "integer" || age.Examples[0] != float64(42) || age.Description != "" {
+		t.Error(age)
+	}
+	if profile.Properties["Email"].Description != "contact email" {
+		t.Error(profile.Properties["Email"])
+	}
+	contact := profile.Properties["Contact"]
+	if contact.Ref != "#/components/schemas/Contact" || contact.Description != "primary contact" {
+		t.Error(contact)
+	}
+	if doc.Components.Schemas["Account"].Properties["Logo"].Description != "" {
+		t.Error("shared definitions should not be modified")
+	}
+
+	// generated docs are keyed by receiver type, so they are found under any namespace
+	registry := rpc.NewRegistry().Add("files", &fileServer{}).Index()
+	doc = schema.OpenAPI(registry, schema.Docs(map[string]string{pkg + ".fileServer.Register": "Register user."}))
+	if summary := doc.Paths["/files.register"].Post.Summary; summary != "Register user." {
+		t.Error("namespaced method should be described, got", summary)
+	}
+}
+
+func TestOpenAPI_security(t *testing.T) {
+	doc := schema.OpenAPI(rpc.Index(&fileServer{}),
+		schema.Security("token", schema.BearerAuth("JWT")),
+		schema.Security("key", schema.HeaderAuth("X-API-Key")),
+		schema.Security("session", schema.CookieAuth("session"), "Bill", "Register"),
+		schema.Security("basic", schema.BasicAuth(), "Bill"),
+		schema.Public("Open"),
+	)
+	if len(doc.Components.SecuritySchemes) != 4 || doc.Components.SecuritySchemes["token"].BearerFormat != "JWT" {
+		t.Fatal(doc.Components.SecuritySchemes)
+	}
+	if len(doc.Security) != 2 || doc.Security[0]["key"] == nil || doc.Security[1]["token"] == nil {
+		t.Error("global alternatives expected", doc.Security)
+	}
+	if bill := doc.Paths["/bill"].Post.Security; len(bill) != 2 || bill[0]["basic"] == nil || bill[1]["session"] == nil {
+		t.Error(bill)
+	}
+	if open := doc.Paths["/open"].Post.Security; len(open) != 1 || len(open[0]) != 0 {
+		t.Error("empty requirement expected", open)
+	}
+	if configure := doc.Paths["/configure"].Post.Security; configure != nil {
+		t.Error("global security expected", configure)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{`"security":[{"key":[]},{"token":[]}]`, `"security":[{}]`, `"session":{"type":"apiKey","in":"cookie","name":"session"}`} {
+		if !strings.Contains(string(data), part) {
+			t.Error("missed", part)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/reddec/rpc/internal/framing"
+	"github.com/reddec/rpc/internal/session"
+)
+
+// Framing of messages in byte stream, see [Serve].
+type Framing int
+
+const (
+	// Lines is newline-delimited JSON: one message per line.
+	Lines Framing = iota
+	// ContentLength is LSP-style framing: Content-Length header, empty line and message.
+	ContentLength
+)
+
+// Serve exposes indexed methods over byte streams (for example, stdin and stdout of subprocess) using the same
+// message protocol as [WebSocket]: requests are read from input, responses are written to output.
+// Calls are executed concurrently and responses are matched by id.
+//
+//	func main() {
+//		err := rpc.Serve(context.Background(), rpc.Index(&srv), os.Stdin, os.Stdout, rpc.Lines)
+//		// ...
+//	}
+//
+//	-> {"id": 1, "method": "sum", "args": [1, 2]}
+//	<- {"id": 1, "result": 3}
+//
+// Request-scoped parameters are resolved from synthetic POST request (without headers) with per-call context derived
+// from ctx. Serve returns nil at the end of input once all in-flight calls are finished. Cancellation of ctx cancels
+// calls, but doesn't interrupt reading from input.
+func Serve(ctx context.Context, index map[string]*ExposedMethod, input io.Reader, output io.Writer, mode Framing) error {
+	base, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+	if err != nil {
+		return err
+	}
+	reader := framing.NewReader(input, mode == ContentLength)
+	writer := framing.NewWriter(output, mode == ContentLength)
+	handler := dispatcher(base, newLookup(index), func(em *ExposedMethod) (reflect.Value, bool) {
+		return em.receiver, true
+	})
+	return session.Serve(ctx, reader.Read, writer.Write, handler, 0)
+}
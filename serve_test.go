@@ -0,0 +1,79 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+func TestServe(t *testing.T) {
+	index := rpc.Index(&ticker{name: "alice"})
+
+	t.Run("lines", func(t *testing.T) {
+		input := `{"id": 1, "method": "hello", "args": ["hi"]}` + "\n\n" +
+			`{"id": "two", "method": "count", "args": [2]}` + "\n" +
+			`{"id": 3, "method": "unknown"}` + "\n"
+		var output bytes.Buffer
+		if err := rpc.Serve(context.Background(), index, strings.NewReader(input), &output, rpc.Lines); err != nil {
+			t.Fatal(err)
+		}
+		var results = make(map[string][]string)
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			var msg struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				t.Fatal(err)
+			}
+			results[string(msg.ID)] = append(results[string(msg.ID)], line)
+		}
+		if v := results["1"]; len(v) != 1 || v[0] != `{"id":1,"result":"hi, alice"}` {
+			t.Error(v)
+		}
+		if v := results[`"two"`]; len(v) != 3 || v[2] != `{"id":"two"}` {
+			t.Error(v)
+		}
+		if v := results["3"]; len(v) != 1 || !strings.Contains(v[0], `"status":404`) {
+			t.Error(v)
+		}
+	})
+
+	t.Run("content length", func(t *testing.T) {
+		message := `{"id": 1, "method": "hello", "args": ["hey"]}`
+		input := "Content-Length: " + strconv.Itoa(len(message)) + "\r\n\r\n" + message
+		var output bytes.Buffer
+		if err := rpc.Serve(context.Background(), index, strings.NewReader(input), &output, rpc.ContentLength); err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"id":1,"result":"hey, alice"}`
+		if output.String() != "Content-Length: "+strconv.Itoa(len(expected))+"\r\n\r\n"+expected {
+			t.Error(output.String())
+		}
+	})
+
+	t.Run("concurrent calls", func(t *testing.T) {
+		reader, writer := io.Pipe()
+		var output bytes.Buffer
+		done := make(chan error, 1)
+		go func() {
+			done <- rpc.Serve(context.Background(), index, reader, &output, rpc.Lines)
+		}()
+		// wait blocks until canceled, so hello should be completed while wait is in-flight
+		_, _ = writer.Write([]byte(`{"id": 1, "method": "wait"}` + "\n"))
+		_, _ = writer.Write([]byte(`{"id": 2, "method": "hello", "args": ["hi"]}` + "\n"))
+		_, _ = writer.Write([]byte(`{"id": 1, "cancel": true}` + "\n"))
+		_ = writer.Close()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(output.String(), `{"id":2,"result":"hi, alice"}`) || !strings.Contains(output.String(), `"id":1,"error"`) {
+			t.Error(output.String())
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/reddec/rpc/internal/session"
+)
+
+// dispatcher executes calls of message-based transports (websocket, stream) by indexed methods.
+// Request-scoped parameters are resolved from base request with per-call context.
+func dispatcher(base *http.Request, methods *lookup, receiver func(em *ExposedMethod) (reflect.Value, bool)) session.Handler {
+	return func(ctx context.Context, msg session.Request, call *session.Call) {
+		em, ok := methods.find(msg.Method)
+		if !ok {
+			_ = call.Fail(http.StatusNotFound, errors.New("unknown method "+msg.Method))
+			return
+		}
+		if len(em.fileTypes) > 0 || em.binary {
+			_ = call.Fail(http.StatusBadRequest, errors.New("files and binary results are supported only over HTTP"))
+			return
+		}
+		root, ok := receiver(em)
+		if !ok {
+			_ = call.Fail(http.StatusInternalServerError, errors.New("service "+em.Namespace()+" is not initialized"))
+			return
+		}
+
+		request := base.WithContext(WithResponse(ctx, NewResponse()))
+		if em.caches != nil {
+			request = em.withCache(request)
+		}
+		if em.async != nil {
+			job, status, err := em.submit(root, request, rawPayload(msg.Args))
+			if err != nil {
+				_ = call.Fail(status, err)
+				return
+			}
+			result, err := json.Marshal(job)
+			if err != nil {
+				_ = call.Fail(http.StatusInternalServerError, err)
+				return
+			}
+			_ = call.Result(result)
+			return
+		}
+		response, appError, status, err := em.run(root, request, rawPayload(msg.Args), nil)
+		if err != nil {
+			_ = call.Fail(status, err)
+			return
+		}
+		if appError != nil {
+			_ = call.Fail(http.StatusInternalServerError, appError)
+			return
+		}
+
+		if em.stream {
+			err := drain(ctx, reflect.ValueOf(response), func(item any) error {
+				data, err := json.Marshal(item)
+				if err != nil {
+					return err
+				}
+				return call.Item(data)
+			})
+			if err != nil {
+				_ = call.Fail(http.StatusInternalServerError, err)
+				return
+			}
+			_ = call.Result(nil)
+			return
+		}
+
+		var result json.RawMessage
+		if em.hasResponse {
+			result, err = json.Marshal(response)
+			if err != nil {
+				_ = call.Fail(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		_ = call.Result(result)
+	}
+}
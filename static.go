@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/reddec/rpc/internal/decode"
+)
+
+// Direct is statically typed call of method of T, usually generated by cmd/rpc-gen. It decodes payload arguments
+// by [Args.Decode] into typed variables, checks them by [Args.Done], takes request-scoped parameters
+// (context.Context, *http.Request, http.Header) from request and calls method without reflection.
+type Direct[T any] func(receiver T, request *http.Request, args *Args) (any, error)
+
+// Static registers direct calls (by Go method name) for receivers of type T, which replace reflection-based calls
+// with identical wire behaviour. Methods without direct call, methods with files, and methods with custom
+// request-scoped parameters (see [Inject]) are called by reflection. Can be used several times for different types
+// (for example for nested services).
+//
+// Calls for pointer type *E (generated by rpc-gen) also serve receivers of type E (for example rpc.Index(server)),
+// unless calls for E are registered: methods with value receiver are called on a copy of receiver.
+//
+//	//go:generate go run github.com/reddec/rpc/cmd/rpc-gen@latest
+//	type Server struct{}
+//
+//	handler := rpc.New(&server, ServerDispatcher()) // ServerDispatcher is generated
+func Static[T any](calls map[string]Direct[T]) Option {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return func(cfg *config) {
+		var methods = make(map[string]direct, len(calls))
+		var values = make(map[string]direct, len(calls))
+		for name, call := range calls {
+			call := call
+			methods[name] = func(receiver reflect.Value, request *http.Request, args *Args) (any, error) {
+				return call(receiver.Interface().(T), request, args)
+			}
+			values[name] = func(receiver reflect.Value, request *http.Request, args *Args) (any, error) {
+				return call(addressOf(receiver).Interface().(T), request, args)
+			}
+		}
+		cfg.static[t] = methods
+		if t.Kind() != reflect.Ptr {
+			return
+		}
+		if _, ok := cfg.static[t.Elem()]; !ok {
+			cfg.static[t.Elem()] = values
+		}
+	}
+}
+
+// addressOf returns pointer to copy of value.
+func addressOf(value reflect.Value) reflect.Value {
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	return ptr
+}
+
+// Args decodes payload (JSON array) directly from request body into arguments, without intermediate representation.
+// It is used by direct calls (see [Direct]) and by reflection-based calls.
+type Args struct {
+	source   decode.Source
+	decoder  *json.Decoder
+	list     bool  // payload is array, not null
+	count    int   // number of decoded arguments
+	expected int   // number of requested arguments
+	err      error // malformed payload, decoder can not continue
+	argErr   error // the first argument which can not be decoded
+}
+
+func newArgs(payload io.Reader) *Args {
+	args := &Args{}
+	args.source.Reader = payload
+	args.decoder = json.NewDecoder(&args.source)
+	token, err := args.decoder.Token()
+	switch {
+	case err != nil:
+		args.err = err
+	case token == nil: // null is empty list
+	case token == json.Delim('['):
+		args.list = true
+	default:
+		args.err = &json.UnmarshalTypeError{Value: tokenKind(token), Type: paramsType, Offset: args.decoder.InputOffset()}
+	}
+	return args
+}
+
+// Decode next argument into value (pointer). Errors are reported by [Args.Done].
+func (a *Args) Decode(value any) {
+	a.expected++
+	if a.err != nil || !a.list || !a.decoder.More() {
+		return
+	}
+	if err := a.decoder.Decode(value); err != nil {
+		a.fail(err)
+	}
+	a.count++
+}
+
+// Done validates and skips extra arguments. Errors have the same precedence as for decoding the whole array first:
+// malformed JSON, then number of arguments, then the first argument which can not be decoded.
+// Error causes 400 Bad Request, same as for reflection-based calls.
+func (a *Args) Done() error {
+	if err := a.done(); err != nil {
+		return &argumentError{err: err}
+	}
+	return nil
+}
+
+func (a *Args) done() error {
+	if a.err == nil && a.list {
+		for a.err == nil && a.decoder.More() {
+			if err := a.decoder.Decode(&skipValue{}); err != nil {
+				a.fail(err)
+			}
+		}
+		if a.err == nil {
+			if _, err := a.decoder.Token(); err != nil {
+				a.err = err
+			}
+		}
+	}
+	if a.err != nil {
+		return a.err
+	}
+	if a.count < a.expected {
+		return errors.New("not enough arguments, expected " + strconv.Itoa(a.expected))
+	}
+	return a.argErr
+}
+
+func (a *Args) fail(err error) {
+	if a.source.Malformed(err) {
+		a.err = err
+	} else if a.argErr == nil {
+		a.argErr = err
+	}
+}
+
+type direct func(receiver reflect.Value, request *http.Request, args *Args) (any, error)
+
+// argumentError is returned by direct call if payload argument can not be decoded.
+type argumentError struct {
+	err error
+}
+
+func (ae *argumentError) Error() string {
+	return ae.err.Error()
+}
+
+func (ae *argumentError) Unwrap() error {
+	return ae.err
+}
+
+// directCall finds direct call of method. Calls are used only if all request-scoped parameters
+// are resolved by default providers and method has no files.
+func directCall(cfg *config, t reflect.Type, method reflect.Method, inputs []input) direct {
+	call, ok := cfg.static[t][method.Name]
+	if !ok {
+		return nil
+	}
+	for i, in := range inputs {
+		if in.file || in.provider != nil && cfg.custom[method.Type.In(i)] {
+			return nil
+		}
+	}
+	return call
+}
@@ -0,0 +1,158 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type staticAPI struct {
+	direct int // number of direct calls
+}
+
+func (s *staticAPI) Sum(ctx context.Context, a, b int) (int, error) {
+	if ctx == nil {
+		return 0, errors.New("no context")
+	}
+	return a + b, nil
+}
+
+func (s *staticAPI) Fail(message string) error {
+	return errors.New(message)
+}
+
+func (s *staticAPI) Who(user string) string {
+	return user
+}
+
+// staticDispatcher is the same as generated by rpc-gen.
+func staticDispatcher() rpc.Option {
+	return rpc.Static(map[string]rpc.Direct[*staticAPI]{
+		"Sum": func(receiver *staticAPI, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 int
+			args.Decode(&arg0)
+			var arg1 int
+			args.Decode(&arg1)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			receiver.direct++
+			return receiver.Sum(request.Context(), arg0, arg1)
+		},
+		"Fail": func(receiver *staticAPI, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 string
+			args.Decode(&arg0)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			receiver.direct++
+			return nil, receiver.Fail(arg0)
+		},
+	})
+}
+
+func TestStatic(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		payload  string
+	}{
+		{"sum", `[1, 2]`},
+		{"sum", `[1]`},
+		{"sum", `[1, "2"]`},
+		{"sum", `["1", 2, 3]`},
+		{"sum", `[1, 2, "extra"]`},
+		{"sum", `[1, 2, }`},
+		{"sum", `{"a": 1}`},
+		{"sum", `null`},
+		{"sum", ``},
+		{"fail", `["oops"]`},
+		{"who", `["alice"]`},
+	}
+
+	var reflected, static staticAPI
+	reflectedHandler := rpc.New(&reflected)
+	staticHandler := rpc.New(&static, staticDispatcher())
+
+	for _, c := range cases {
+		expected := httptest.NewRecorder()
+		reflectedHandler.ServeHTTP(expected, httptest.NewRequest(http.MethodPost, "/"+c.endpoint, strings.NewReader(c.payload)))
+		actual := httptest.NewRecorder()
+		staticHandler.ServeHTTP(actual, httptest.NewRequest(http.MethodPost, "/"+c.endpoint, strings.NewReader(c.payload)))
+
+		if expected.Code != actual.Code || expected.Body.String() != actual.Body.String() {
+			t.Errorf("%s %s: expected %d %q, got %d %q", c.endpoint, c.payload, expected.Code, expected.Body.String(), actual.Code, actual.Body.String())
+		}
+		if expected.Header().Get("Content-Type") != actual.Header().Get("Content-Type") {
+			t.Errorf("%s %s: content type mismatch", c.endpoint, c.payload)
+		}
+	}
+	// invalid payload is checked before call
+	if static.direct != 3 {
+		t.Errorf("expected 3 direct calls, got %d", static.direct)
+	}
+	if reflected.direct != 0 {
+		t.Errorf("unexpected direct calls")
+	}
+}
+
+func TestStatic_inject(t *testing.T) {
+	var api staticAPI
+	handler := rpc.New(&api, staticDispatcher(), rpc.Inject(func(r *http.Request) (context.Context, error) {
+		return r.Context(), nil
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/sum", strings.NewReader(`[1, 2]`)))
+	if res.Code != http.StatusOK || strings.TrimSpace(res.Body.String()) != "3" {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	if api.direct != 0 {
+		t.Errorf("method with custom provider should be called by reflection")
+	}
+}
+
+type valueAPI struct {
+	direct *int // number of direct calls, shared by copies of receiver
+}
+
+func (v valueAPI) Double(value int) int {
+	return value * 2
+}
+
+func (v *valueAPI) Reset() {
+	*v.direct = 0
+}
+
+func TestStatic_value(t *testing.T) {
+	var direct int
+	// generated by rpc-gen for *valueAPI
+	dispatcher := rpc.Static(map[string]rpc.Direct[*valueAPI]{
+		"Double": func(receiver *valueAPI, request *http.Request, args *rpc.Args) (any, error) {
+			var arg0 int
+			args.Decode(&arg0)
+			if err := args.Done(); err != nil {
+				return nil, err
+			}
+			*receiver.direct++
+			return receiver.Double(arg0), nil
+		},
+	})
+
+	index := rpc.Index(valueAPI{direct: &direct}, dispatcher)
+	if _, ok := index["Reset"]; ok {
+		t.Fatal("methods with pointer receiver should not be indexed for value")
+	}
+	res := httptest.NewRecorder()
+	index["Double"].ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/double", strings.NewReader(`[21]`)))
+	if res.Code != http.StatusOK || strings.TrimSpace(res.Body.String()) != "42" {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	if direct != 1 {
+		t.Errorf("receiver indexed by value should be dispatched directly, got %d direct calls", direct)
+	}
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// isStream checks that type is receivable channel.
+func isStream(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0
+}
+
+// drain reads items from channel until it's closed, context is done or handler returned error.
+// Nil channel is considered as empty stream.
+func drain(ctx context.Context, ch reflect.Value, handler func(item any) error) error {
+	if ch.IsNil() {
+		return nil
+	}
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return ctx.Err()
+		}
+		if !ok {
+			return nil
+		}
+		if err := handler(item.Interface()); err != nil {
+			return err
+		}
+	}
+}
+
+// writeStream writes items from channel as newline-delimited JSON, flushing after each item.
+func writeStream(writer http.ResponseWriter, request *http.Request, response any, status int) {
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.WriteHeader(status)
+	flusher, _ := writer.(http.Flusher)
+	encoder := json.NewEncoder(writer)
+	_ = drain(request.Context(), reflect.ValueOf(response), func(item any) error {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}) // too late to do anything
+}
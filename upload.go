@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"github.com/reddec/rpc/internal/upload"
+)
+
+// File is uploaded file which can be used as method argument. Content is streamed from request
+// and valid only during call. See [Index] for details.
+//
+//	func (srv *Server) Upload(ctx context.Context, folder string, file rpc.File) error
+type File = upload.File
+
+// DefaultMaxUploadArgs is default limit of "args" part of multipart request, see [MaxUploadArgs].
+const DefaultMaxUploadArgs = upload.DefaultMaxArgs
+
+// MaxUploadArgs limits size (in bytes) of "args" part of multipart request, which is read into memory.
+// Larger parts are rejected by 413 Request Entity Too Large. Zero means no limit. Default is [DefaultMaxUploadArgs].
+func MaxUploadArgs(size int64) Option {
+	return func(cfg *config) {
+		cfg.uploads.Args = size
+	}
+}
+
+// MaxUploadSpool limits size (in bytes) of each file stored to temporary file: all files except the last one
+// when streaming, see [Index]. Larger files are rejected by 413 Request Entity Too Large. Default is zero (no limit),
+// so temporary files are limited only by disk space.
+func MaxUploadSpool(size int64) Option {
+	return func(cfg *config) {
+		cfg.uploads.Spool = size
+	}
+}
@@ -0,0 +1,130 @@
+package rpc_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/reddec/rpc"
+)
+
+type storage struct{}
+
+func (s *storage) Save(prefix string, doc rpc.File, content io.Reader) (string, error) {
+	data, err := io.ReadAll(doc)
+	if err != nil {
+		return "", err
+	}
+	tail, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	return prefix + ":" + doc.Name + ":" + string(data) + ":" + string(tail), nil
+}
+
+func (s *storage) Size(header *multipart.FileHeader) int64 {
+	return header.Size
+}
+
+func multipartRequest(t *testing.T, path string, args string, files ...string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	if args != "" {
+		if err := form.WriteField("args", args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, content := range files {
+		w, err := form.CreateFormFile("file"+strconv.Itoa(i), "doc.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestUpload(t *testing.T) {
+	index := rpc.Index(&storage{})
+	if files := index["Save"].Files(); len(files) != 2 {
+		t.Fatal("expected 2 files, got", len(files))
+	}
+	if args := index["Save"].Args(); len(args) != 1 {
+		t.Fatal("files should be excluded from args, got", len(args))
+	}
+	router := rpc.Router(index)
+
+	t.Run("stream", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, multipartRequest(t, "/save", `["x"]`, "hello", "world"))
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `"x:doc.txt:hello:world"` {
+			t.Error(rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("form", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, multipartRequest(t, "/size", "", "12345"))
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `5` {
+			t.Error(rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, multipartRequest(t, "/save", `["x"]`, "hello"))
+		if rec.Code != http.StatusBadRequest {
+			t.Error(rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("json request", func(t *testing.T) {
+		if code, _ := call(t, router, "/save", `["x"]`); code != http.StatusBadRequest {
+			t.Error("non-multipart request should be rejected, got", code)
+		}
+	})
+}
+
+func TestUpload_limits(t *testing.T) {
+	router := rpc.New(&storage{}, rpc.MaxUploadArgs(16), rpc.MaxUploadSpool(8))
+
+	cases := []struct {
+		name  string
+		args  string
+		files []string
+		code  int
+	}{
+		{"within limits", `["x"]`, []string{"12345678", "last file is not limited"}, http.StatusOK},
+		{"large args", `["` + strings.Repeat("x", 16) + `"]`, []string{"hello", "world"}, http.StatusRequestEntityTooLarge},
+		{"large spooled file", `["x"]`, []string{"123456789", "world"}, http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, multipartRequest(t, "/save", c.args, c.files...))
+			if rec.Code != c.code {
+				t.Error(rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		args := `["` + strings.Repeat("x", rpc.DefaultMaxUploadArgs) + `"]`
+		rpc.New(&storage{}).ServeHTTP(rec, multipartRequest(t, "/save", args, "hello", "world"))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Error(rec.Code)
+		}
+	})
+}
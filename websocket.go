@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/reddec/rpc/internal/origin"
+	"github.com/reddec/rpc/internal/session"
+	"github.com/reddec/rpc/internal/websocket"
+)
+
+// WebSocket exposes indexed methods over websocket connection. Handshake and framing are implemented
+// in the library (RFC 6455, without extensions).
+//
+// Client sends text messages with call requests, and server replies with messages matched by id.
+// Many calls can be in-flight in the same connection, each call is executed in separate goroutine.
+//
+//	-> {"id": 1, "method": "sum", "args": [1, 2]}
+//	<- {"id": 1, "result": 3}
+//	<- {"id": 2, "error": "not enough arguments, expected 2", "status": 400}
+//
+// Id can be any JSON value (number or string), method is endpoint name or alias (exact name first,
+// then case-insensitive), args is JSON array of arguments as for HTTP.
+//
+// Streams (methods returning <-chan T) push each item as separate message, the last message without item
+// (and with error if stream was interrupted) completes the call:
+//
+//	<- {"id": 3, "item": {...}}
+//	<- {"id": 3, "item": {...}}
+//	<- {"id": 3}
+//
+// In-flight call can be canceled by client (method context is canceled):
+//
+//	-> {"id": 3, "cancel": true}
+//
+// Request-scoped parameters are resolved from upgrade request with per-call context, which is canceled
+// once call completed or connection closed. Response metadata ([ResponseFrom]) is ignored. Methods with files
+// or binary results are not supported over websocket (status 400).
+//
+// Status codes (in status field) are the same as for HTTP: 400, 404 and 500. Calls exceeding limit of in-flight
+// calls per connection ([MaxCalls]) fail with 429.
+//
+// Browsers send cookies with cross-site websocket handshakes, so handshake with Origin header from other host
+// is rejected with 403 Forbidden, unless origin is allowed by [AllowOrigins]. Only websocket options
+// ([AllowOrigins] and [MaxCalls]) are used, methods are configured by index.
+func WebSocket(index map[string]*ExposedMethod, options ...Option) http.Handler {
+	cfg := newConfig(options)
+	methods := newLookup(index)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !origin.Allowed(request, cfg.origins) {
+			http.Error(writer, "origin is not allowed", http.StatusForbidden)
+			return
+		}
+		conn, err := websocket.Upgrade(writer, request)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = serveWebSocket(conn, request, methods, cfg.maxCalls, func(em *ExposedMethod) (reflect.Value, bool) {
+			return em.receiver, true
+		})
+	})
+}
+
+// WebSocketBuilder is [Builder] for websocket: receiver (aka session) is created once per connection
+// by factory from upgrade request. Factory error causes 500 Internal Server Error before upgrade.
+// Origin is checked before factory is called. See [WebSocket] for protocol details.
+//
+//	http.Handle("/ws", rpc.WebSocketBuilder(server.newAPI))
+func WebSocketBuilder[T any](factory func(r *http.Request) (T, error), options ...Option) http.Handler {
+	var t T
+	cfg := newConfig(options)
+	methods := newLookup(Index(t, options...))
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !websocket.IsUpgrade(request) {
+			http.Error(writer, "websocket upgrade expected", http.StatusBadRequest)
+			return
+		}
+		if !origin.Allowed(request, cfg.origins) {
+			http.Error(writer, "origin is not allowed", http.StatusForbidden)
+			return
+		}
+		value, err := factory(request)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(err.Error()))
+			return
+		}
+		conn, err := websocket.Upgrade(writer, request)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		root := reflect.ValueOf(value)
+		_ = serveWebSocket(conn, request, methods, cfg.maxCalls, func(em *ExposedMethod) (reflect.Value, bool) {
+			return em.resolve(root)
+		})
+	})
+}
+
+// DefaultMaxCalls is default limit of in-flight calls per websocket connection, see [MaxCalls].
+const DefaultMaxCalls = 64
+
+// AllowOrigins allows websocket handshakes from other origins: full origin (ex: https://app.example.com),
+// host (ex: app.example.com:8080) or * for any origin. Handshakes from the same host and without Origin header
+// (non-browser clients) are always allowed.
+func AllowOrigins(origins ...string) Option {
+	return func(cfg *config) {
+		cfg.origins = append(cfg.origins, origins...)
+	}
+}
+
+// MaxCalls limits number of in-flight calls per websocket connection (zero means unlimited).
+// Default is [DefaultMaxCalls].
+func MaxCalls(limit int) Option {
+	return func(cfg *config) {
+		cfg.maxCalls = limit
+	}
+}
+
+var errClosed = errors.New("connection closed")
+
+func serveWebSocket(conn *websocket.Conn, request *http.Request, methods *lookup, limit int, receiver func(em *ExposedMethod) (reflect.Value, bool)) error {
+	read := func() ([]byte, error) {
+		_, data, err := conn.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return nil, errClosed // peer is gone, in-flight calls should be canceled
+		}
+		return data, err
+	}
+	write := func(data []byte) error {
+		return conn.WriteMessage(websocket.Text, data)
+	}
+	return session.Serve(request.Context(), read, write, dispatcher(request, methods, receiver), limit)
+}
@@ -0,0 +1,316 @@
+package rpc_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddec/rpc"
+	"github.com/reddec/rpc/internal/websocket"
+)
+
+type ticker struct {
+	name string
+}
+
+func (tk *ticker) Hello(greeting string) string {
+	return greeting + ", " + tk.name
+}
+
+func (tk *ticker) Count(ctx context.Context, n int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 0; i < n; i++ {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (tk *ticker) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type wsMessage struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Item   json.RawMessage `json:"item"`
+	Error  string          `json:"error"`
+	Status int             `json:"status"`
+}
+
+func dial(t *testing.T, handler http.Handler) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := websocket.Dial(ctx, strings.Replace(srv.URL, "http://", "ws://", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, message string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.Text, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg wsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestWebSocket(t *testing.T) {
+	conn := dial(t, rpc.WebSocket(rpc.Index(&ticker{name: "alice"})))
+
+	t.Run("call", func(t *testing.T) {
+		send(t, conn, `{"id": 1, "method": "hello", "args": ["hi"]}`)
+		if msg := receive(t, conn); msg.ID != 1 || string(msg.Result) != `"hi, alice"` {
+			t.Error(msg)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		send(t, conn, `{"id": 2, "method": "unknown"}`)
+		if msg := receive(t, conn); msg.ID != 2 || msg.Status != http.StatusNotFound {
+			t.Error(msg)
+		}
+		send(t, conn, `{"id": 3, "method": "hello"}`)
+		if msg := receive(t, conn); msg.ID != 3 || msg.Status != http.StatusBadRequest {
+			t.Error(msg)
+		}
+	})
+
+	t.Run("multiplexed with cancel", func(t *testing.T) {
+		send(t, conn, `{"id": 4, "method": "wait"}`)
+		send(t, conn, `{"id": 5, "method": "hello", "args": ["bye"]}`)
+		if msg := receive(t, conn); msg.ID != 5 || string(msg.Result) != `"bye, alice"` {
+			t.Error(msg)
+		}
+		send(t, conn, `{"id": 4, "cancel": true}`)
+		if msg := receive(t, conn); msg.ID != 4 || msg.Status != http.StatusInternalServerError {
+			t.Error(msg)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		send(t, conn, `{"id": 6, "method": "count", "args": [3]}`)
+		for i := 0; i < 3; i++ {
+			if msg := receive(t, conn); msg.ID != 6 || string(msg.Item) != strconv.Itoa(i) {
+				t.Error(i, msg)
+			}
+		}
+		if msg := receive(t, conn); msg.ID != 6 || msg.Item != nil || msg.Error != "" {
+			t.Error(msg)
+		}
+	})
+}
+
+func TestWebSocketBuilder(t *testing.T) {
+	var sessions int
+	handler := rpc.WebSocketBuilder(func(r *http.Request) (*ticker, error) {
+		sessions++
+		return &ticker{name: r.URL.Query().Get("name")}, nil
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	conn, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/?name=bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 1; i <= 2; i++ {
+		send(t, conn, `{"id": 1, "method": "Hello", "args": ["hey"]}`)
+		if msg := receive(t, conn); string(msg.Result) != `"hey, bob"` {
+			t.Error(msg)
+		}
+	}
+	if sessions != 1 {
+		t.Error("session should be created once per connection, got", sessions)
+	}
+
+	if code, _ := call(t, handler, "/", `[]`); code != http.StatusBadRequest {
+		t.Error("non-websocket request should be rejected, got", code)
+	}
+}
+
+func TestWebSocket_origin(t *testing.T) {
+	var sessions int
+	handler := rpc.WebSocketBuilder(func(r *http.Request) (*ticker, error) {
+		sessions++
+		return &ticker{}, nil
+	}, rpc.AllowOrigins("https://app.example.com"))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	url := strings.Replace(srv.URL, "http://", "ws://", 1)
+
+	for origin, allowed := range map[string]bool{
+		"":                            true,
+		srv.URL:                       true,
+		"https://APP.example.com":     true,
+		"https://evil.example.com":    false,
+		"null":                        false,
+		"http://app.example.com:8080": false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, err := websocket.Dial(context.Background(), url, header)
+		if (err == nil) != allowed {
+			t.Error(origin, "allowed:", allowed, "got:", err)
+		}
+		if err == nil {
+			_ = conn.Close()
+		}
+	}
+	if sessions != 3 {
+		t.Error("session should not be created for rejected origin, got", sessions)
+	}
+}
+
+func TestWebSocket_maxCalls(t *testing.T) {
+	conn := dial(t, rpc.WebSocket(rpc.Index(&ticker{}), rpc.MaxCalls(1)))
+	send(t, conn, `{"id": 1, "method": "wait"}`)
+	send(t, conn, `{"id": 2, "method": "wait"}`)
+	if msg := receive(t, conn); msg.ID != 2 || msg.Status != http.StatusTooManyRequests {
+		t.Error(msg)
+	}
+	send(t, conn, `{"id": 1, "cancel": true}`)
+	if msg := receive(t, conn); msg.ID != 1 || msg.Status != http.StatusInternalServerError {
+		t.Error(msg)
+	}
+	send(t, conn, `{"id": 3, "method": "hello", "args": ["hi"]}`)
+	if msg := receive(t, conn); msg.ID != 3 || msg.Error != "" {
+		t.Error("slot should be released", msg)
+	}
+}
+
+// rawDial connects to websocket handler without client, so test can send arbitrary frames.
+func rawDial(t *testing.T, handler http.Handler) (*net.TCPConn, *bufio.Reader) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := request.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil || response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("handshake failed", err)
+	}
+	return conn.(*net.TCPConn), reader
+}
+
+// frameHeader of masked client frame with zero mask, so payload is sent as-is.
+func frameHeader(opcode byte, final bool, size uint64) []byte {
+	var header = []byte{opcode, 0x80}
+	if final {
+		header[0] |= 0x80
+	}
+	switch {
+	case size < 126:
+		header[1] |= byte(size)
+	case size <= 0xffff:
+		header[1] |= 126
+		header = binary.BigEndian.AppendUint16(header, uint16(size))
+	default:
+		header[1] |= 127
+		header = binary.BigEndian.AppendUint64(header, size)
+	}
+	return append(header, 0, 0, 0, 0)
+}
+
+func TestWebSocket_frames(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame []byte
+		code  uint16
+	}{
+		{"fragmented control frame", frameHeader(0x9, false, 0), 1002},
+		{"large control frame", append(frameHeader(0x9, true, 126), make([]byte, 126)...), 1002},
+		{"unexpected continuation", frameHeader(0x0, true, 0), 1002},
+		{"too large frame", frameHeader(0x1, true, 1<<40), 1009},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, reader := rawDial(t, rpc.WebSocket(rpc.Index(&ticker{})))
+			if _, err := conn.Write(c.frame); err != nil {
+				t.Fatal(err)
+			}
+			var reply [4]byte
+			if _, err := io.ReadFull(reader, reply[:]); err != nil {
+				t.Fatal(err)
+			}
+			if reply[0] != 0x88 || reply[1] != 2 || binary.BigEndian.Uint16(reply[2:]) != c.code {
+				t.Errorf("expected close frame with code %d, got %v", c.code, reply)
+			}
+		})
+	}
+
+	t.Run("declared size is not allocated in advance", func(t *testing.T) {
+		conn, reader := rawDial(t, rpc.WebSocket(rpc.Index(&ticker{})))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, _ = conn.Write(append(frameHeader(0x1, true, 16<<20), "truncated"...))
+		_ = conn.CloseWrite()
+		_, _ = io.Copy(io.Discard, reader) // server closes connection once frame is broken
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+			t.Errorf("payload should be read as it arrives, allocated %d bytes", allocated)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	index := rpc.Index(&ticker{})
+	if !index["Count"].Stream() {
+		t.Fatal("channel result should be stream")
+	}
+	code, body := call(t, rpc.Router(index), "/count", `[3]`)
+	if code != http.StatusOK || body != "0\n1\n2" {
+		t.Error(code, body)
+	}
+}